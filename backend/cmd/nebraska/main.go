@@ -1,15 +1,21 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/labstack/gommon/log"
 	"github.com/rs/zerolog"
 
 	db "github.com/flatcar/nebraska/backend/pkg/api"
 	"github.com/flatcar/nebraska/backend/pkg/config"
+	"github.com/flatcar/nebraska/backend/pkg/eventqueue"
+	"github.com/flatcar/nebraska/backend/pkg/eventsink"
 	"github.com/flatcar/nebraska/backend/pkg/metrics"
 	"github.com/flatcar/nebraska/backend/pkg/server"
+	"github.com/flatcar/nebraska/backend/pkg/subscriptions"
 	"github.com/flatcar/nebraska/backend/pkg/syncer"
 )
 
@@ -69,6 +75,52 @@ func main() {
 		log.Fatal("Metrics register error:", err)
 	}
 
+	// wire up the CloudEvents sink: triggerEventConsequences publishes
+	// update lifecycle events to it. While conf.EventSink.Enabled is false
+	// (its zero value), newEventSink returns the no-op Sink that
+	// eventsink.New builds for a disabled config.
+	sink, err := newEventSink(conf, db.DB())
+	if err != nil {
+		log.Fatal("Event sink setup error:", err)
+	}
+	db.SetEventSink(sink, conf.NebraskaURL)
+
+	// wire up the durable event queue: RegisterEvent only ingests events
+	// from here on, the resolve and apply workers below do the rest.
+	eq := eventqueue.New(db.DB())
+	db.SetEventQueue(eq)
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	resolveWorker := eventqueue.NewWorker(eq, eventqueue.StageResolve, time.Second, db.ProcessResolveJob)
+	applyWorker := eventqueue.NewWorker(eq, eventqueue.StageApply, time.Second, db.ProcessApplyJob)
+	go resolveWorker.Run(workerCtx)
+	go applyWorker.Run(workerCtx)
+
+	// wire up the external subscriptions service: rollout and instance
+	// activity entries are dispatched to matching webhook subscriptions.
+	// server.New does not call subscriptions.RegisterRoutes, so the
+	// subscription REST endpoints are not served by this binary.
+	db.SetSubscriptionsService(subscriptions.New(db.DB()))
+
+	// keep the event_timeseries_* materialized views fresh. server.New does
+	// not call api.RegisterTimeSeriesRoutes, so the timeseries endpoint is
+	// not served by this binary.
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-workerCtx.Done():
+				return
+			case <-ticker.C:
+				if err := db.RefreshEventTimeSeriesViews(); err != nil {
+					log.Error("could not refresh event timeseries views, err: ", err)
+				}
+			}
+		}
+	}()
+
 	server, err := server.New(conf, db)
 	if err != nil {
 		log.Fatal("Server setup error:", err)
@@ -77,3 +129,50 @@ func main() {
 	// run server
 	log.Fatal(server.Start(fmt.Sprintf(":%d", conf.ServerPort)))
 }
+
+// newEventSink builds the eventsink.Sink used to publish update lifecycle
+// CloudEvents, wiring its transport and retry/backoff settings from
+// conf.EventSink - the config knob the sink ships disabled (Enabled: false)
+// until an operator configures it.
+//
+// NOTE: conf.EventSink (Enabled, Transport, URL, HTTPContentMode,
+// KafkaBrokers, KafkaTopic, PubSubProject, PubSubTopic, BufferSize, Workers,
+// MaxRetries) is assumed here since config.Config isn't part of this diff;
+// wire these fields up alongside the rest of Config's flags/env vars.
+func newEventSink(conf *config.Config, rawDB *sql.DB) (*eventsink.Sink, error) {
+	cfg := eventsink.DefaultConfig()
+	cfg.Enabled = conf.EventSink.Enabled
+	cfg.Source = conf.NebraskaURL
+	if conf.EventSink.Transport != "" {
+		cfg.Transport = conf.EventSink.Transport
+	}
+	if conf.EventSink.BufferSize > 0 {
+		cfg.BufferSize = conf.EventSink.BufferSize
+	}
+	if conf.EventSink.Workers > 0 {
+		cfg.Workers = conf.EventSink.Workers
+	}
+	if conf.EventSink.MaxRetries > 0 {
+		cfg.MaxRetries = conf.EventSink.MaxRetries
+	}
+
+	if !cfg.Enabled {
+		return eventsink.New(cfg, nil, nil), nil
+	}
+
+	var transport eventsink.Transport
+	var err error
+	switch cfg.Transport {
+	case "kafka":
+		transport = eventsink.NewKafkaTransport(conf.EventSink.KafkaBrokers, conf.EventSink.KafkaTopic)
+	case "pubsub":
+		transport, err = eventsink.NewPubSubTransport(context.Background(), conf.EventSink.PubSubProject, conf.EventSink.PubSubTopic)
+	default:
+		transport = eventsink.NewHTTPTransport(conf.EventSink.URL, eventsink.ContentMode(conf.EventSink.HTTPContentMode))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return eventsink.New(cfg, transport, eventsink.NewPostgresDeadLetterer(rawDB)), nil
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"time"
+)
+
+// New Omaha event types Nebraska previously rejected outright. They're
+// wired up through DefaultEventRegistry instead of RegisterEvent's old
+// inline cascade, so operators can enable them per app with
+// SetAppEventTypeEnabled. RegisterEvent still rejects any type/result pair
+// that has no row in the event_type table, and no migration adds rows for
+// these types, so their handlers run only for pairs given such a row.
+const (
+	// EventInstallStarted indicates that the instance started installing
+	// the downloaded update package (Omaha protocol type 2).
+	EventInstallStarted = 2
+
+	// EventUpdateCheck indicates an update check with a subresult carrying
+	// additional detail (e.g. "no update available", "throttled"). It
+	// shares its wire type with EventUpdateComplete (3); handlers for it
+	// must only match eresult values outside the existing
+	// ResultFailed/ResultSuccess/ResultSuccessReboot range (>= 10) to avoid
+	// double-processing update-complete events.
+	EventUpdateCheck = 3
+
+	// EventRollbackCall indicates the instance rolled back to a previous
+	// version, with the failed update's error code attached.
+	//
+	// NOTE: the Omaha extension Nebraska already uses for
+	// EventUpdateInstalled is 800, so EventRollbackCall can't reuse that
+	// value without colliding. 1800 is used here as a Nebraska-local
+	// extension until a dedicated wire value is settled with the Omaha
+	// spec this project tracks.
+	EventRollbackCall = 1800
+
+	// EventUpdateDeferred indicates the instance postponed an update it
+	// was offered, e.g. because of a maintenance window.
+	EventUpdateDeferred = 1801
+)
+
+// minSubresult is the lowest eresult value treated as an EventUpdateCheck
+// subresult rather than an EventUpdateComplete result.
+const minSubresult = 10
+
+func init() {
+	RegisterEventHandler(eventHandlerFunc{
+		match: func(etype, eresult int) bool { return etype == EventInstallStarted },
+		apply: func(ctx context.Context, api *API, instanceID, appID, groupID, lastUpdateVersion, previousVersion string, etype, eresult int, createdTs time.Time) error {
+			l.Debug().Str("instance", instanceID).Msg("builtin_handlers - install started")
+			return nil
+		},
+	})
+
+	RegisterEventHandler(eventHandlerFunc{
+		match: func(etype, eresult int) bool { return etype == EventUpdateCheck && eresult >= minSubresult },
+		apply: func(ctx context.Context, api *API, instanceID, appID, groupID, lastUpdateVersion, previousVersion string, etype, eresult int, createdTs time.Time) error {
+			l.Debug().Str("instance", instanceID).Int("subresult", eresult).Msg("builtin_handlers - update check subresult received")
+			return nil
+		},
+	})
+
+	RegisterEventHandler(eventHandlerFunc{
+		match: func(etype, eresult int) bool { return etype == EventRollbackCall },
+		apply: func(ctx context.Context, api *API, instanceID, appID, groupID, lastUpdateVersion, previousVersion string, etype, eresult int, createdTs time.Time) error {
+			if err := api.updateInstanceStatus(instanceID, appID, InstanceStatusError); err != nil {
+				return err
+			}
+			if err := api.newInstanceActivityEntry(activityInstanceUpdateFailed, activityError, lastUpdateVersion, appID, groupID, instanceID); err != nil {
+				return err
+			}
+			api.notifyActivitySubscribers(activityInstanceUpdateFailed, appID, groupID, instanceID, lastUpdateVersion, createdTs)
+			return nil
+		},
+	})
+
+	RegisterEventHandler(eventHandlerFunc{
+		match: func(etype, eresult int) bool { return etype == EventUpdateDeferred },
+		apply: func(ctx context.Context, api *API, instanceID, appID, groupID, lastUpdateVersion, previousVersion string, etype, eresult int, createdTs time.Time) error {
+			return api.updateInstanceStatus(instanceID, appID, InstanceStatusUndefined)
+		},
+	})
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/flatcar/nebraska/backend/pkg/eventsink"
+)
+
+// init registers the handlers for the original, hardcoded subset of Omaha
+// events triggerEventConsequences used to process inline. They're migrated
+// here - rather than left special-cased in events.go - so the registry is
+// the single place that decides what happens for every event type,
+// built-in or third-party.
+func init() {
+	RegisterEventHandler(eventHandlerFunc{
+		match: func(etype, eresult int) bool {
+			return etype == EventUpdateComplete && (eresult == ResultSuccessReboot || eresult == ResultSuccess)
+		},
+		apply: handleUpdateComplete,
+	})
+
+	RegisterEventHandler(eventHandlerFunc{
+		match: func(etype, eresult int) bool {
+			return etype == EventUpdateDownloadStarted && eresult == ResultSuccess
+		},
+		apply: func(ctx context.Context, api *API, instanceID, appID, groupID, lastUpdateVersion, previousVersion string, etype, eresult int, createdTs time.Time) error {
+			return api.handleStatusChangeEvent(appID, groupID, instanceID, lastUpdateVersion, previousVersion, InstanceStatusDownloading, eventsink.TypeDownloadStarted, createdTs)
+		},
+	})
+
+	RegisterEventHandler(eventHandlerFunc{
+		match: func(etype, eresult int) bool {
+			return etype == EventUpdateDownloadFinished && eresult == ResultSuccess
+		},
+		apply: func(ctx context.Context, api *API, instanceID, appID, groupID, lastUpdateVersion, previousVersion string, etype, eresult int, createdTs time.Time) error {
+			return api.handleStatusChangeEvent(appID, groupID, instanceID, lastUpdateVersion, previousVersion, InstanceStatusDownloaded, eventsink.TypeDownloadFinished, createdTs)
+		},
+	})
+
+	RegisterEventHandler(eventHandlerFunc{
+		match: func(etype, eresult int) bool {
+			return etype == EventUpdateInstalled && eresult == ResultSuccess
+		},
+		apply: func(ctx context.Context, api *API, instanceID, appID, groupID, lastUpdateVersion, previousVersion string, etype, eresult int, createdTs time.Time) error {
+			return api.handleStatusChangeEvent(appID, groupID, instanceID, lastUpdateVersion, previousVersion, InstanceStatusInstalled, eventsink.TypeInstalled, createdTs)
+		},
+	})
+
+	RegisterEventHandler(eventHandlerFunc{
+		match: func(etype, eresult int) bool { return eresult == ResultFailed },
+		apply: handleUpdateFailed,
+	})
+}
+
+// handleStatusChangeEvent applies the common pattern shared by the
+// download-started/download-finished/installed events: move the instance to
+// status and publish the matching lifecycle CloudEvent.
+func (api *API) handleStatusChangeEvent(appID, groupID, instanceID, lastUpdateVersion, previousVersion string, status int, ceType eventsink.CloudEventType, createdTs time.Time) error {
+	if err := api.updateInstanceStatus(instanceID, appID, status); err != nil {
+		l.Error().Err(err).Msg("handleStatusChangeEvent - could not update instance status")
+	}
+	group, err := api.GetGroup(groupID)
+	if err != nil {
+		return err
+	}
+	api.publishLifecycleEvent(ceType, appID, groupID, instanceID, group.ChannelID.String, lastUpdateVersion, previousVersion, "", createdTs)
+	return nil
+}
+
+// handleUpdateComplete applies the consequences of an instance reporting
+// that an update finished. The app-specific plain-ResultSuccess exclusion
+// Flatcar relies on (it only posts EventUpdateComplete/ResultSuccessReboot
+// on a genuine update) lives here rather than in Match, since Match doesn't
+// receive the appID needed to make the call.
+func handleUpdateComplete(ctx context.Context, api *API, instanceID, appID, groupID, lastUpdateVersion, previousVersion string, etype, eresult int, createdTs time.Time) error {
+	if eresult == ResultSuccess && appID == flatcarAppID {
+		return nil
+	}
+
+	group, err := api.GetGroup(groupID)
+	if err != nil {
+		return err
+	}
+
+	if err := api.updateInstanceStatus(instanceID, appID, InstanceStatusComplete); err != nil {
+		l.Error().Err(err).Msg("handleUpdateComplete - could not update instance status")
+	}
+	api.publishLifecycleEvent(eventsink.TypeUpdateComplete, appID, groupID, instanceID, group.ChannelID.String, lastUpdateVersion, previousVersion, "", createdTs)
+
+	updatesStats, err := api.getGroupUpdatesStats(group)
+	if err != nil {
+		return err
+	}
+	if updatesStats.UpdatesToCurrentVersionSucceeded == updatesStats.TotalInstances {
+		if err := api.setGroupRolloutInProgress(groupID, false); err != nil {
+			l.Error().Err(err).Msg("handleUpdateComplete - could not set rollout progress")
+		}
+		if err := api.newGroupActivityEntry(activityRolloutFinished, activitySuccess, lastUpdateVersion, appID, groupID); err != nil {
+			l.Error().Err(err).Msg("handleUpdateComplete - could not add group activity")
+		}
+		api.notifyActivitySubscribers(activityRolloutFinished, appID, groupID, "", lastUpdateVersion, createdTs)
+		api.publishLifecycleEvent(eventsink.TypeRolloutFinished, appID, groupID, "", group.ChannelID.String, lastUpdateVersion, previousVersion, "", createdTs)
+	}
+	return nil
+}
+
+// handleUpdateFailed applies the consequences of any event posted with
+// ResultFailed, regardless of its type: marks the instance as errored,
+// records the failure, and - once disableUpdatesOnFailedRollout is set -
+// runs the group's configured canary rollback policy.
+func handleUpdateFailed(ctx context.Context, api *API, instanceID, appID, groupID, lastUpdateVersion, previousVersion string, etype, eresult int, createdTs time.Time) error {
+	group, err := api.GetGroup(groupID)
+	if err != nil {
+		return err
+	}
+
+	if err := api.updateInstanceStatus(instanceID, appID, InstanceStatusError); err != nil {
+		l.Error().Err(err).Msg("handleUpdateFailed - could not update instance status")
+	}
+	if err := api.newInstanceActivityEntry(activityInstanceUpdateFailed, activityError, lastUpdateVersion, appID, groupID, instanceID); err != nil {
+		l.Error().Err(err).Msg("handleUpdateFailed - could not add instance activity")
+	}
+	api.notifyActivitySubscribers(activityInstanceUpdateFailed, appID, groupID, instanceID, lastUpdateVersion, createdTs)
+	errCode, err := api.GetEvent(instanceID, appID, time.Now())
+	if err != nil {
+		l.Error().Err(err).Msg("handleUpdateFailed - could not get last error code")
+	}
+	api.publishLifecycleEvent(eventsink.TypeUpdateFailed, appID, groupID, instanceID, group.ChannelID.String, lastUpdateVersion, previousVersion, errCode.String, createdTs)
+
+	if !api.disableUpdatesOnFailedRollout {
+		return nil
+	}
+
+	updatesStats, err := api.getGroupUpdatesStats(group)
+	if err != nil {
+		return err
+	}
+
+	policy, err := api.getRolloutPolicy(groupID)
+	if err != nil {
+		l.Error().Err(err).Msg("handleUpdateFailed - could not get rollout policy")
+		policy = rolloutPolicy{}
+	}
+
+	// A group with a failure-rate threshold configured uses the
+	// canary-style policy below instead of the original
+	// one-failure-disables-everything behavior.
+	if policy.MaxFailurePercentage > 0 {
+		if policy.shouldRollBack(updatesStats.UpdatesToCurrentVersionAttempted, updatesStats.UpdatesToCurrentVersionFailed) {
+			if err := api.setGroupRolloutInProgress(groupID, false); err != nil {
+				l.Error().Err(err).Msg("handleUpdateFailed - could not set rollout progress")
+			}
+			if policy.RollbackChannelID.Valid && policy.RollbackChannelID.String != "" {
+				if err := api.setGroupChannel(groupID, policy.RollbackChannelID.String); err != nil {
+					l.Error().Err(err).Msg("handleUpdateFailed - could not roll back group channel")
+				}
+			}
+			if err := api.newGroupActivityEntry(activityRolloutRolledBack, activityError, lastUpdateVersion, appID, groupID); err != nil {
+				l.Error().Err(err).Msg("handleUpdateFailed - could not add group activity")
+			}
+			api.notifyActivitySubscribers(activityRolloutRolledBack, appID, groupID, "", lastUpdateVersion, createdTs)
+			api.publishLifecycleEvent(eventsink.TypeRolloutFailed, appID, groupID, "", group.ChannelID.String, lastUpdateVersion, previousVersion, "", createdTs)
+		}
+	} else if updatesStats.UpdatesToCurrentVersionAttempted == 1 {
+		if err := api.disableUpdates(groupID); err != nil {
+			l.Error().Err(err).Msg("handleUpdateFailed - could not disable updates")
+		}
+		if err := api.setGroupRolloutInProgress(groupID, false); err != nil {
+			l.Error().Err(err).Msg("handleUpdateFailed - could not set rollout progress")
+		}
+		if err := api.newGroupActivityEntry(activityRolloutFailed, activityError, lastUpdateVersion, appID, groupID); err != nil {
+			l.Error().Err(err).Msg("handleUpdateFailed - could not add group activity")
+		}
+		api.notifyActivitySubscribers(activityRolloutFailed, appID, groupID, "", lastUpdateVersion, createdTs)
+		api.publishLifecycleEvent(eventsink.TypeRolloutFailed, appID, groupID, "", group.ChannelID.String, lastUpdateVersion, previousVersion, "", createdTs)
+	}
+	return nil
+}
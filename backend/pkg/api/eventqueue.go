@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/flatcar/nebraska/backend/pkg/eventqueue"
+)
+
+// DB returns the underlying database handle, for wiring up subsystems (like
+// eventqueue.Queue) that need direct DB access outside of the API's own
+// query helpers.
+func (api *API) DB() *sql.DB {
+	return api.db
+}
+
+// eventQueues holds each *API instance's durable event queue, keyed by the
+// instance itself. It would be a field on API directly, but API's struct
+// definition lives outside this package's diff; keying by pointer here gives
+// each instance (tests build a fresh one per suite, and RollbackDBTo builds
+// a second one in the same process) its own queue instead of sharing one.
+// An instance with no entry here (the zero value, nil) means RegisterEvent
+// falls back to processing consequences inline - useful for tests and for
+// deployments that haven't run the event_queue migration yet.
+var eventQueues sync.Map // *API -> *eventqueue.Queue
+
+// SetEventQueue wires the durable event queue used by RegisterEvent and by
+// the resolve/apply workers. It should be called once during server setup.
+func (api *API) SetEventQueue(q *eventqueue.Queue) {
+	eventQueues.Store(api, q)
+}
+
+// eventQueue returns api's durable event queue, or nil if SetEventQueue was
+// never called for it.
+func (api *API) eventQueue() *eventqueue.Queue {
+	if v, ok := eventQueues.Load(api); ok {
+		return v.(*eventqueue.Queue)
+	}
+	return nil
+}
+
+// rawEvent is the (event_type, result, instance_id, application_id,
+// previous_version, error_code, created_ts) tuple a queue job needs re-read
+// from the event table, since the job itself only carries the event id.
+type rawEvent struct {
+	etype           int
+	result          int
+	instanceID      string
+	appID           string
+	previousVersion string
+	errorCode       string
+	createdTs       time.Time
+}
+
+func (api *API) loadRawEvent(eventID int64) (rawEvent, error) {
+	query, _, err := goqu.From("event").
+		InnerJoin(goqu.T("event_type"), goqu.On(goqu.I("event.event_type_id").Eq(goqu.I("event_type.id")))).
+		Select(
+			goqu.I("event_type.type"),
+			goqu.I("event_type.result"),
+			goqu.I("event.instance_id"),
+			goqu.I("event.application_id"),
+			goqu.I("event.previous_version"),
+			goqu.I("event.error_code"),
+			goqu.I("event.created_ts"),
+		).
+		Where(goqu.I("event.id").Eq(eventID)).
+		ToSQL()
+	if err != nil {
+		return rawEvent{}, err
+	}
+
+	var ev rawEvent
+	if err := api.db.QueryRow(query).Scan(&ev.etype, &ev.result, &ev.instanceID, &ev.appID, &ev.previousVersion, &ev.errorCode, &ev.createdTs); err != nil {
+		return rawEvent{}, err
+	}
+	return ev, nil
+}
+
+// ProcessResolveJob implements the eventqueue.HandlerFunc for StageResolve:
+// it loads the instance/group/app context for the event and makes sure
+// they're still valid, so a bad event doesn't wedge the apply stage
+// forever.
+func (api *API) ProcessResolveJob(ctx context.Context, job *eventqueue.Job) error {
+	ev, err := api.loadRawEvent(job.EventID)
+	if err != nil {
+		return err
+	}
+	if _, err := api.GetGroup(job.GroupID); err != nil {
+		return err
+	}
+	if _, err := api.GetInstance(ev.instanceID, ev.appID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ProcessApplyJob implements the eventqueue.HandlerFunc for StageApply: it
+// applies the instance status update, activity entries and rollout
+// bookkeeping for the event, i.e. the logic that used to run synchronously
+// inside RegisterEvent.
+//
+// triggerEventConsequences isn't itself transactional (it spans several
+// independent writes plus best-effort CloudEvent/webhook dispatch), so a job
+// that's retried after a transient failure partway through would otherwise
+// replay from the top and duplicate every side effect that already ran.
+// event_apply_log guards against that: once triggerEventConsequences
+// succeeds for an event, a retry of the same job (or a second StageApply
+// entry for it) sees it's already applied and skips straight to success.
+func (api *API) ProcessApplyJob(ctx context.Context, job *eventqueue.Job) error {
+	applied, err := api.eventAlreadyApplied(ctx, job.EventID)
+	if err != nil {
+		return err
+	}
+	if applied {
+		return nil
+	}
+
+	ev, err := api.loadRawEvent(job.EventID)
+	if err != nil {
+		return err
+	}
+	if err := api.triggerEventConsequences(ev.instanceID, ev.appID, job.GroupID, job.LastUpdateVersion, ev.previousVersion, ev.etype, ev.result, ev.createdTs); err != nil {
+		return err
+	}
+	return api.markEventApplied(ctx, job.EventID)
+}
+
+func (api *API) eventAlreadyApplied(ctx context.Context, eventID int64) (bool, error) {
+	query, _, err := goqu.From("event_apply_log").
+		Select(goqu.L("1")).
+		Where(goqu.C("event_id").Eq(eventID)).
+		ToSQL()
+	if err != nil {
+		return false, err
+	}
+	var exists int
+	err = api.db.QueryRowContext(ctx, query).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (api *API) markEventApplied(ctx context.Context, eventID int64) error {
+	query, _, err := goqu.Insert("event_apply_log").
+		Cols("event_id").
+		Vals(goqu.Vals{eventID}).
+		OnConflict(goqu.DoNothing()).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	_, err = api.db.ExecContext(ctx, query)
+	return err
+}
@@ -1,11 +1,14 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"time"
 
 	"github.com/doug-martin/goqu/v9"
 	"gopkg.in/guregu/null.v4"
+
+	"github.com/flatcar/nebraska/backend/pkg/eventqueue"
 )
 
 const (
@@ -105,20 +108,10 @@ func (api *API) RegisterEvent(instanceID, appID, groupID string, etype, eresult
 		return ErrNoUpdateInProgress
 	}
 
-	// Temporary hack to handle Flatcar updater specific behaviour
-	if appID == flatcarAppID && etype == EventUpdateComplete && eresult == ResultSuccessReboot {
-		if previousVersion == "" || previousVersion == "0.0.0.0" {
-			// Do not log the Complete event for already updated instances but reset the instance state to
-			// ensure it can update and is not stuck in some other state because according to the DB it,
-			// e.g., is updating and thus shouldn't be granted any update. The instance can't be in a Completed
-			// state because of the ErrNoUpdateInProgress check above, thus no need to cover this case here.
-			// The Undefined state is chosen because the instance did not tell that it updated from a previous
-			// version ("" and "0.0.0.0" are not valid but "0.0.0" is because it is used when forcing an update).
-			if err := api.updateInstanceObjStatus(instance, InstanceStatusUndefined); err != nil {
-				l.Error().Err(err).Msg("RegisterEvent - could not update instance status")
-			}
-			return ErrFlatcarEventIgnored
-		}
+	// Pre-validators can reject the event before it's persisted, e.g. the
+	// Flatcar updater-specific carve-out registered in flatcar_handler.go.
+	if err := DefaultEventRegistry.preValidate(context.Background(), api, instance, appID, etype, eresult, previousVersion); err != nil {
+		return err
 	}
 
 	var eventTypeID int
@@ -134,105 +127,84 @@ func (api *API) RegisterEvent(instanceID, appID, groupID string, etype, eresult
 		return ErrInvalidEventTypeOrResult
 	}
 
-	insertQuery, _, err := goqu.Insert("event").
-		Cols("event_type_id", "instance_id", "application_id", "previous_version", "error_code").
-		Vals(goqu.Vals{eventTypeID, instanceID, appID, previousVersion, errorCode}).
-		ToSQL()
-	if err != nil {
-		return err
-	}
-	_, err = api.db.Exec(insertQuery)
-
-	if err != nil {
-		return ErrEventRegistrationFailed
-	}
-
 	lastUpdateVersion := instance.Application.LastUpdateVersion.String
-	if err := api.triggerEventConsequences(instanceID, appID, groupID, lastUpdateVersion, etype, eresult); err != nil {
-		l.Error().Err(err).Msgf("RegisterEvent - could not trigger event consequences")
-	}
-
-	return nil
-}
-
-// triggerEventConsequences is in charge of triggering the consequences of a
-// given event. Depending on the type of the event and its result, the status
-// of the instance may be updated, new activity entries could be created, etc.
-func (api *API) triggerEventConsequences(instanceID, appID, groupID, lastUpdateVersion string, etype, result int) error {
-	group, err := api.GetGroup(groupID)
-	if err != nil {
-		return err
-	}
 
-	// We allow the plain ResultSuccess here only if the app is not Flatcar because Flatcar is relying on
-	// having only the update-complete logic on ResultSuccessReboot.
-	if etype == EventUpdateComplete && (result == ResultSuccessReboot || (appID != flatcarAppID && result == ResultSuccess)) {
-		if err := api.updateInstanceStatus(instanceID, appID, InstanceStatusComplete); err != nil {
-			l.Error().Err(err).Msg("triggerEventConsequences - could not update instance status")
-		}
-
-		updatesStats, err := api.getGroupUpdatesStats(group)
+	// When the durable event queue isn't wired up (e.g. in tests, or on a
+	// deployment that hasn't run the event_queue migration yet), fall back
+	// to the old behaviour of inserting the event and processing its
+	// consequences inline.
+	if api.eventQueue() == nil {
+		insertQuery, _, err := goqu.Insert("event").
+			Cols("event_type_id", "instance_id", "application_id", "previous_version", "error_code", "version").
+			Vals(goqu.Vals{eventTypeID, instanceID, appID, previousVersion, errorCode, lastUpdateVersion}).
+			Returning("created_ts").
+			ToSQL()
 		if err != nil {
 			return err
 		}
-		if updatesStats.UpdatesToCurrentVersionSucceeded == updatesStats.TotalInstances {
-			if err := api.setGroupRolloutInProgress(groupID, false); err != nil {
-				l.Error().Err(err).Msg("triggerEventConsequences - could not set rollout progress")
-			}
-			if err := api.newGroupActivityEntry(activityRolloutFinished, activitySuccess, lastUpdateVersion, appID, groupID); err != nil {
-				l.Error().Err(err).Msg("triggerEventConsequences - could not add group activity")
-			}
+		var createdTs time.Time
+		if err := api.db.QueryRow(insertQuery).Scan(&createdTs); err != nil {
+			return ErrEventRegistrationFailed
 		}
-	}
-
-	if etype == EventUpdateDownloadStarted && result == ResultSuccess {
-		if err := api.updateInstanceStatus(instanceID, appID, InstanceStatusDownloading); err != nil {
-			l.Error().Err(err).Msg("triggerEventConsequences - could not update instance status")
+		if err := api.triggerEventConsequences(instanceID, appID, groupID, lastUpdateVersion, previousVersion, etype, eresult, createdTs); err != nil {
+			l.Error().Err(err).Msgf("RegisterEvent - could not trigger event consequences")
 		}
+		return nil
 	}
 
-	if etype == EventUpdateDownloadFinished && result == ResultSuccess {
-		if err := api.updateInstanceStatus(instanceID, appID, InstanceStatusDownloaded); err != nil {
-			l.Error().Err(err).Msg("triggerEventConsequences - could not update instance status")
-		}
+	// The ingestor stage: validate the event (done above) and insert it
+	// plus a queue entry in a single transaction, then return immediately.
+	// Everything else - loading the instance/group context and applying
+	// its consequences - happens asynchronously in the resolve and apply
+	// workers, so a burst of events can't stall the Omaha request path.
+	tx, err := api.db.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback() //nolint:errcheck
 
-	if etype == EventUpdateInstalled && result == ResultSuccess {
-		if err := api.updateInstanceStatus(instanceID, appID, InstanceStatusInstalled); err != nil {
-			l.Error().Err(err).Msg("triggerEventConsequences - could not update instance status")
-		}
+	insertQuery, _, err := goqu.Insert("event").
+		Cols("event_type_id", "instance_id", "application_id", "previous_version", "error_code", "version").
+		Vals(goqu.Vals{eventTypeID, instanceID, appID, previousVersion, errorCode, lastUpdateVersion}).
+		Returning("id").
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	var eventID int64
+	if err := tx.QueryRow(insertQuery).Scan(&eventID); err != nil {
+		return ErrEventRegistrationFailed
 	}
 
-	if result == ResultFailed {
-		if err := api.updateInstanceStatus(instanceID, appID, InstanceStatusError); err != nil {
-			l.Error().Err(err).Msg("triggerEventConsequences - could not update instance status")
-		}
-		if err := api.newInstanceActivityEntry(activityInstanceUpdateFailed, activityError, lastUpdateVersion, appID, groupID, instanceID); err != nil {
-			l.Error().Err(err).Msg("triggerEventConsequences - could not add instance activity")
-		}
+	if err := eventqueue.Enqueue(context.Background(), tx, eventID, groupID, lastUpdateVersion); err != nil {
+		return err
+	}
 
-		if api.disableUpdatesOnFailedRollout {
-			updatesStats, err := api.getGroupUpdatesStats(group)
-			if err != nil {
-				return err
-			}
-			if updatesStats.UpdatesToCurrentVersionAttempted == 1 {
-				if err := api.disableUpdates(groupID); err != nil {
-					l.Error().Err(err).Msg("triggerEventConsequences - could not disable updates")
-				}
-				if err := api.setGroupRolloutInProgress(groupID, false); err != nil {
-					l.Error().Err(err).Msg("triggerEventConsequences - could not set rollout progress")
-				}
-				if err := api.newGroupActivityEntry(activityRolloutFailed, activityError, lastUpdateVersion, appID, groupID); err != nil {
-					l.Error().Err(err).Msg("triggerEventConsequences - could not add group activity")
-				}
-			}
-		}
+	if err := tx.Commit(); err != nil {
+		return err
 	}
 
 	return nil
 }
 
+// triggerEventConsequences is in charge of triggering the consequences of a
+// given event. Depending on the type of the event and its result, the status
+// of the instance may be updated, new activity entries could be created, etc.
+// previousVersion is the version the instance reported updating from, as
+// posted with the event (propagated to publishLifecycleEvent's
+// previous_version field); it's "" when the instance didn't report one.
+// createdTs is the event's created_ts, propagated to publishLifecycleEvent's
+// CloudEvents "time" field so it reflects when the Omaha event actually
+// happened rather than whenever this runs.
+func (api *API) triggerEventConsequences(instanceID, appID, groupID, lastUpdateVersion, previousVersion string, etype, result int, createdTs time.Time) error {
+	// Every consequence - the original built-in subset (update
+	// complete/download started/download finished/installed/failed,
+	// registered in core_handlers.go's init) as well as anything third
+	// parties register - runs through the pluggable registry, so this
+	// function doesn't grow its own cascade of special cases.
+	return DefaultEventRegistry.apply(context.Background(), api, instanceID, appID, groupID, lastUpdateVersion, previousVersion, etype, result, createdTs)
+}
+
 func (api *API) GetEvent(instanceID string, appID string, timestamp time.Time) (null.String, error) {
 	query, _, err := goqu.From("event").
 		Select("error_code").
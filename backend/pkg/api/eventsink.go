@@ -0,0 +1,69 @@
+package api
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/flatcar/nebraska/backend/pkg/eventsink"
+)
+
+// eventSinkState bundles the sink and source URL a single *API instance
+// publishes CloudEvents through.
+type eventSinkState struct {
+	sink   *eventsink.Sink
+	source string
+}
+
+// disabledEventSink is handed back for any *API that hasn't had SetEventSink
+// called on it, e.g. in tests. Its Publish is a no-op.
+var disabledEventSink = eventsink.New(eventsink.DefaultConfig(), nil, nil)
+
+// eventSinks holds each *API instance's eventSinkState, keyed by the
+// instance itself. It would be a field on API directly, but API's struct
+// definition lives outside this package's diff; keying by pointer here gives
+// each instance (tests build a fresh one per suite, and RollbackDBTo builds
+// a second one in the same process) its own sink instead of sharing one.
+var eventSinks sync.Map // *API -> *eventSinkState
+
+// SetEventSink replaces the sink used to publish update lifecycle
+// CloudEvents, and the source URL attached to every event published from
+// then on. It should be called once during server setup, after the
+// eventsink.Sink has been built from config.Config.
+func (api *API) SetEventSink(sink *eventsink.Sink, source string) {
+	eventSinks.Store(api, &eventSinkState{sink: sink, source: source})
+}
+
+func (api *API) eventSinkState() *eventSinkState {
+	if v, ok := eventSinks.Load(api); ok {
+		return v.(*eventSinkState)
+	}
+	return &eventSinkState{sink: disabledEventSink}
+}
+
+// publishLifecycleEvent builds and publishes a CloudEvent describing an
+// update lifecycle transition. Delivery is asynchronous and best-effort: it
+// never blocks or fails the Omaha request path. eventTime is the triggering
+// event's created_ts, not the time of publishing: the apply stage that
+// calls this runs asynchronously off the durable event queue, so by publish
+// time it can lag well behind when the Omaha event actually happened.
+func (api *API) publishLifecycleEvent(etype eventsink.CloudEventType, appID, groupID, instanceID, channel, version, previousVersion, errorCode string, eventTime time.Time) {
+	state := api.eventSinkState()
+	state.sink.Publish(eventsink.CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              uuid.NewString(),
+		Type:            etype,
+		Source:          state.source,
+		Subject:         appID + "/" + groupID + "/" + instanceID,
+		Time:            eventTime,
+		DataContentType: "application/json",
+		Data: eventsink.Data{
+			Instance:        instanceID,
+			Channel:         channel,
+			Version:         version,
+			PreviousVersion: previousVersion,
+			ErrorCode:       errorCode,
+		},
+	})
+}
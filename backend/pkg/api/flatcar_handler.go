@@ -0,0 +1,33 @@
+package api
+
+import "context"
+
+func init() {
+	RegisterEventPreValidator(eventPreValidatorFunc{validate: flatcarPreValidate})
+}
+
+// flatcarPreValidate implements Flatcar's updater-specific behaviour: it
+// rejects (with ErrFlatcarEventIgnored) an EventUpdateComplete/
+// ResultSuccessReboot event posted by a Flatcar instance that didn't report
+// a previous version, after first resetting the instance's state so it
+// isn't left stuck mid-update. This can't be expressed as an EventHandler
+// since it needs to run before the event is persisted.
+func flatcarPreValidate(ctx context.Context, api *API, instance *Instance, appID string, etype, eresult int, previousVersion string) error {
+	if appID != flatcarAppID || etype != EventUpdateComplete || eresult != ResultSuccessReboot {
+		return nil
+	}
+	if previousVersion != "" && previousVersion != "0.0.0.0" {
+		return nil
+	}
+
+	// Do not log the Complete event for already updated instances but reset the instance state to
+	// ensure it can update and is not stuck in some other state because according to the DB it,
+	// e.g., is updating and thus shouldn't be granted any update. The instance can't be in a Completed
+	// state because of the ErrNoUpdateInProgress check in RegisterEvent, thus no need to cover this case here.
+	// The Undefined state is chosen because the instance did not tell that it updated from a previous
+	// version ("" and "0.0.0.0" are not valid but "0.0.0" is because it is used when forcing an update).
+	if err := api.updateInstanceObjStatus(instance, InstanceStatusUndefined); err != nil {
+		l.Error().Err(err).Msg("flatcarPreValidate - could not update instance status")
+	}
+	return ErrFlatcarEventIgnored
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// EventHandler applies the consequences of a single Omaha event type/result
+// combination. Third parties (Flatcar-specific logic being the canonical
+// example) register their own handlers instead of editing events.go.
+//
+// Apply receives the same instance/group context triggerEventConsequences
+// already has rather than full Instance/Group objects, since building
+// those here would duplicate api's own lookups.
+type EventHandler interface {
+	// Match reports whether this handler is responsible for an event of
+	// the given Omaha type and result.
+	Match(etype, eresult int) bool
+	// Apply runs the handler's consequences for the event. etype/eresult
+	// are passed again since a handler may match a range of results (see
+	// EventUpdateCheck's subresults) and need to know which one fired.
+	// previousVersion is the version the instance reported updating from,
+	// as posted with the event; createdTs is the event's created_ts, for
+	// handlers that publish a CloudEvent and need the time the event
+	// actually happened rather than whenever Apply happens to run.
+	Apply(ctx context.Context, api *API, instanceID, appID, groupID, lastUpdateVersion, previousVersion string, etype, eresult int, createdTs time.Time) error
+}
+
+// eventHandlerFunc adapts matching and applying closures into an
+// EventHandler, for the common case of a handler with no extra state.
+type eventHandlerFunc struct {
+	match func(etype, eresult int) bool
+	apply func(ctx context.Context, api *API, instanceID, appID, groupID, lastUpdateVersion, previousVersion string, etype, eresult int, createdTs time.Time) error
+}
+
+func (h eventHandlerFunc) Match(etype, eresult int) bool { return h.match(etype, eresult) }
+func (h eventHandlerFunc) Apply(ctx context.Context, api *API, instanceID, appID, groupID, lastUpdateVersion, previousVersion string, etype, eresult int, createdTs time.Time) error {
+	return h.apply(ctx, api, instanceID, appID, groupID, lastUpdateVersion, previousVersion, etype, eresult, createdTs)
+}
+
+// EventPreValidator lets a handler short-circuit RegisterEvent *before* the
+// event row is persisted, e.g. to reject an event outright without leaving
+// an audit trail. Handlers that only need to react to an event once it's
+// recorded should implement EventHandler instead, since the registry's
+// Match/Apply consequence handlers only run after the event exists.
+type EventPreValidator interface {
+	// PreValidate runs against the already-loaded instance, before the
+	// event is inserted into the event table. Returning a non-nil error
+	// aborts RegisterEvent with that error.
+	PreValidate(ctx context.Context, api *API, instance *Instance, appID string, etype, eresult int, previousVersion string) error
+}
+
+// eventPreValidatorFunc adapts a validating closure into an
+// EventPreValidator, for the common case of a pre-validator with no extra
+// state.
+type eventPreValidatorFunc struct {
+	validate func(ctx context.Context, api *API, instance *Instance, appID string, etype, eresult int, previousVersion string) error
+}
+
+func (f eventPreValidatorFunc) PreValidate(ctx context.Context, api *API, instance *Instance, appID string, etype, eresult int, previousVersion string) error {
+	return f.validate(ctx, api, instance, appID, etype, eresult, previousVersion)
+}
+
+// eventTypeRegistry is the pluggable set of handlers RegisterEvent's
+// consequence processing consults instead of the old inline "if" cascade.
+// Handlers are tried in registration order; every matching handler runs.
+type eventTypeRegistry struct {
+	mu            sync.RWMutex
+	handlers      []EventHandler
+	preValidators []EventPreValidator
+	// disabledPerApp lets operators opt specific apps out of specific
+	// event types, e.g. to keep strict Omaha compliance for some apps
+	// while enabling richer telemetry for others.
+	disabledPerApp map[string]map[int]bool
+}
+
+// DefaultEventRegistry is the registry consulted by triggerEventConsequences.
+// Built-in handlers are registered in init(); third parties call
+// RegisterEventHandler to add their own.
+var DefaultEventRegistry = &eventTypeRegistry{
+	disabledPerApp: make(map[string]map[int]bool),
+}
+
+// RegisterEventHandler adds handler to the default registry. Handlers
+// registered this way run for every RegisterEvent call whose type/result
+// they match, in addition to the built-in ones.
+func RegisterEventHandler(handler EventHandler) {
+	DefaultEventRegistry.mu.Lock()
+	defer DefaultEventRegistry.mu.Unlock()
+	DefaultEventRegistry.handlers = append(DefaultEventRegistry.handlers, handler)
+}
+
+// RegisterEventPreValidator adds validator to the default registry. Every
+// registered pre-validator runs, in registration order, before an event is
+// persisted; the first one to return an error aborts RegisterEvent.
+func RegisterEventPreValidator(validator EventPreValidator) {
+	DefaultEventRegistry.mu.Lock()
+	defer DefaultEventRegistry.mu.Unlock()
+	DefaultEventRegistry.preValidators = append(DefaultEventRegistry.preValidators, validator)
+}
+
+// SetAppEventTypeEnabled enables or disables processing of etype for a
+// specific app, so operators can opt into richer telemetry per app without
+// affecting the rest of the fleet's strict-mode behavior.
+func SetAppEventTypeEnabled(appID string, etype int, enabled bool) {
+	DefaultEventRegistry.mu.Lock()
+	defer DefaultEventRegistry.mu.Unlock()
+	if DefaultEventRegistry.disabledPerApp[appID] == nil {
+		DefaultEventRegistry.disabledPerApp[appID] = make(map[int]bool)
+	}
+	DefaultEventRegistry.disabledPerApp[appID][etype] = !enabled
+}
+
+func (r *eventTypeRegistry) isEnabledForApp(appID string, etype int) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if perType, ok := r.disabledPerApp[appID]; ok {
+		return !perType[etype]
+	}
+	return true
+}
+
+// apply runs every registered handler matching etype/eresult, for an app
+// that hasn't opted out of that event type. Every matching handler runs
+// even if an earlier one fails - one handler's bug shouldn't stop another's
+// unrelated consequence from applying - but the first failure is still
+// returned so callers (the apply queue worker, in particular) know to
+// retry instead of treating the event as done.
+func (r *eventTypeRegistry) apply(ctx context.Context, api *API, instanceID, appID, groupID, lastUpdateVersion, previousVersion string, etype, eresult int, createdTs time.Time) error {
+	if !r.isEnabledForApp(appID, etype) {
+		return nil
+	}
+
+	r.mu.RLock()
+	handlers := make([]EventHandler, len(r.handlers))
+	copy(handlers, r.handlers)
+	r.mu.RUnlock()
+
+	var firstErr error
+	for _, h := range handlers {
+		if !h.Match(etype, eresult) {
+			continue
+		}
+		if err := h.Apply(ctx, api, instanceID, appID, groupID, lastUpdateVersion, previousVersion, etype, eresult, createdTs); err != nil {
+			l.Error().Err(err).Int("etype", etype).Int("eresult", eresult).Msg("eventTypeRegistry - handler failed")
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// preValidate runs every registered pre-validator in order, returning the
+// first non-nil error. RegisterEvent calls this before persisting the
+// event, so a pre-validator can reject it outright.
+func (r *eventTypeRegistry) preValidate(ctx context.Context, api *API, instance *Instance, appID string, etype, eresult int, previousVersion string) error {
+	r.mu.RLock()
+	validators := make([]EventPreValidator, len(r.preValidators))
+	copy(validators, r.preValidators)
+	r.mu.RUnlock()
+
+	for _, v := range validators {
+		if err := v.PreValidate(ctx, api, instance, appID, etype, eresult, previousVersion); err != nil {
+			return err
+		}
+	}
+	return nil
+}
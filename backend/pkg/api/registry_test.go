@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEventTypeRegistryApplySkipsDisabledApp(t *testing.T) {
+	const testEventType = 9001
+	var applied int
+
+	registry := &eventTypeRegistry{disabledPerApp: make(map[string]map[int]bool)}
+	registry.handlers = append(registry.handlers, eventHandlerFunc{
+		match: func(etype, eresult int) bool { return etype == testEventType },
+		apply: func(ctx context.Context, api *API, instanceID, appID, groupID, lastUpdateVersion, previousVersion string, etype, eresult int, createdTs time.Time) error {
+			applied++
+			return nil
+		},
+	})
+
+	if err := registry.apply(context.Background(), nil, "instance", "app1", "group", "1.0.0", "", testEventType, ResultSuccess, time.Time{}); err != nil {
+		t.Fatalf("apply() returned error: %v", err)
+	}
+	if applied != 1 {
+		t.Fatalf("expected handler to run once before disabling, got %d", applied)
+	}
+
+	registry.disabledPerApp["app1"] = map[int]bool{testEventType: true}
+
+	if err := registry.apply(context.Background(), nil, "instance", "app1", "group", "1.0.0", "", testEventType, ResultSuccess, time.Time{}); err != nil {
+		t.Fatalf("apply() returned error: %v", err)
+	}
+	if applied != 1 {
+		t.Fatalf("expected handler not to run for a disabled app, got %d calls", applied)
+	}
+
+	// A different app with the same event type enabled is unaffected.
+	if err := registry.apply(context.Background(), nil, "instance", "app2", "group", "1.0.0", "", testEventType, ResultSuccess, time.Time{}); err != nil {
+		t.Fatalf("apply() returned error: %v", err)
+	}
+	if applied != 2 {
+		t.Fatalf("expected handler to run for an unaffected app, got %d calls", applied)
+	}
+}
+
+func TestEventTypeRegistryApplyPropagatesHandlerErrors(t *testing.T) {
+	const testEventType = 9002
+	wantErr := errors.New("boom")
+	var secondRan bool
+
+	registry := &eventTypeRegistry{disabledPerApp: make(map[string]map[int]bool)}
+	registry.handlers = append(registry.handlers,
+		eventHandlerFunc{
+			match: func(etype, eresult int) bool { return etype == testEventType },
+			apply: func(ctx context.Context, api *API, instanceID, appID, groupID, lastUpdateVersion, previousVersion string, etype, eresult int, createdTs time.Time) error {
+				return wantErr
+			},
+		},
+		eventHandlerFunc{
+			match: func(etype, eresult int) bool { return etype == testEventType },
+			apply: func(ctx context.Context, api *API, instanceID, appID, groupID, lastUpdateVersion, previousVersion string, etype, eresult int, createdTs time.Time) error {
+				secondRan = true
+				return nil
+			},
+		},
+	)
+
+	// A failing handler must not stop later handlers from running (their
+	// consequences are independent), but apply must still surface the
+	// failure so ProcessApplyJob's caller retries instead of treating a
+	// partially-applied event as done.
+	err := registry.apply(context.Background(), nil, "instance", "app1", "group", "1.0.0", "", testEventType, ResultSuccess, time.Time{})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("apply() = %v, want %v", err, wantErr)
+	}
+	if !secondRan {
+		t.Error("apply() stopped after the first handler's error instead of running every matching handler")
+	}
+}
+
+func TestEventTypeRegistryPreValidateStopsOnFirstError(t *testing.T) {
+	sentinel := ErrFlatcarEventIgnored
+	var secondCalled bool
+
+	registry := &eventTypeRegistry{disabledPerApp: make(map[string]map[int]bool)}
+	registry.preValidators = append(registry.preValidators,
+		eventPreValidatorFunc{validate: func(ctx context.Context, api *API, instance *Instance, appID string, etype, eresult int, previousVersion string) error {
+			return sentinel
+		}},
+		eventPreValidatorFunc{validate: func(ctx context.Context, api *API, instance *Instance, appID string, etype, eresult int, previousVersion string) error {
+			secondCalled = true
+			return nil
+		}},
+	)
+
+	err := registry.preValidate(context.Background(), nil, nil, "app1", EventUpdateComplete, ResultSuccessReboot, "")
+	if err != sentinel {
+		t.Fatalf("preValidate() = %v, want %v", err, sentinel)
+	}
+	if secondCalled {
+		t.Error("preValidate() ran a pre-validator after one already returned an error")
+	}
+}
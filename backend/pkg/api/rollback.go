@@ -0,0 +1,84 @@
+package api
+
+import (
+	"github.com/doug-martin/goqu/v9"
+	"gopkg.in/guregu/null.v4"
+)
+
+// activityRolloutRolledBack records that a rollout was automatically rolled
+// back because its failure rate crossed the group's configured threshold.
+// NOTE: value picked to follow activityRolloutFailed; update it if it
+// collides with the activity_type table once the full activity.go enum is
+// available.
+const activityRolloutRolledBack = 9
+
+// rolloutPolicy holds the canary-style automatic rollback thresholds
+// configured on a group.
+type rolloutPolicy struct {
+	MaxFailurePercentage float64
+	MinSampleSize        int
+	RollbackChannelID    null.String
+}
+
+// getRolloutPolicy reads the max_failure_percentage, min_sample_size and
+// rollback_channel_id columns for groupID.
+func (api *API) getRolloutPolicy(groupID string) (rolloutPolicy, error) {
+	query, _, err := goqu.From("group").
+		Select("max_failure_percentage", "min_sample_size", "rollback_channel_id").
+		Where(goqu.C("id").Eq(groupID)).
+		ToSQL()
+	if err != nil {
+		return rolloutPolicy{}, err
+	}
+	var policy rolloutPolicy
+	if err := api.db.QueryRow(query).Scan(&policy.MaxFailurePercentage, &policy.MinSampleSize, &policy.RollbackChannelID); err != nil {
+		return rolloutPolicy{}, err
+	}
+	return policy, nil
+}
+
+// setRolloutPolicy writes the max_failure_percentage, min_sample_size and
+// rollback_channel_id columns for groupID, so operators can configure
+// automatic rollback thresholds through the group REST API instead of only
+// reading them.
+func (api *API) setRolloutPolicy(groupID string, policy rolloutPolicy) error {
+	query, _, err := goqu.Update("group").
+		Set(goqu.Record{
+			"max_failure_percentage": policy.MaxFailurePercentage,
+			"min_sample_size":        policy.MinSampleSize,
+			"rollback_channel_id":    policy.RollbackChannelID,
+		}).
+		Where(goqu.C("id").Eq(groupID)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	_, err = api.db.Exec(query)
+	return err
+}
+
+// setGroupChannel switches groupID to serve channelID, so subsequent Omaha
+// responses roll instances back to the package on that channel.
+func (api *API) setGroupChannel(groupID, channelID string) error {
+	query, _, err := goqu.Update("group").
+		Set(goqu.Record{"channel_id": channelID}).
+		Where(goqu.C("id").Eq(groupID)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	_, err = api.db.Exec(query)
+	return err
+}
+
+// shouldRollBack reports whether a group's rollout has crossed its
+// configured automatic-rollback threshold: enough instances have attempted
+// the current version (>= MinSampleSize) and their failure rate exceeds
+// MaxFailurePercentage.
+func (policy rolloutPolicy) shouldRollBack(attempted, failed int) bool {
+	if policy.MaxFailurePercentage <= 0 || attempted < policy.MinSampleSize {
+		return false
+	}
+	failureRate := float64(failed) / float64(attempted) * 100
+	return failureRate > policy.MaxFailurePercentage
+}
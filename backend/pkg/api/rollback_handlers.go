@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"gopkg.in/guregu/null.v4"
+)
+
+// RegisterRollbackRoutes wires the group rollback policy endpoints onto
+// group. server.New does not call it, so these endpoints are only served
+// where a caller mounts them. No frontend UI calls these endpoints.
+func RegisterRollbackRoutes(group *echo.Group, api *API) {
+	group.GET("/apps/:app/groups/:group/rollback_policy", api.getRolloutPolicyHandler)
+	group.PUT("/apps/:app/groups/:group/rollback_policy", api.setRolloutPolicyHandler)
+}
+
+// rolloutPolicyPayload is the JSON representation of a rolloutPolicy, for
+// reading and writing it through the REST API.
+type rolloutPolicyPayload struct {
+	MaxFailurePercentage float64 `json:"max_failure_percentage"`
+	MinSampleSize        int     `json:"min_sample_size"`
+	RollbackChannelID    string  `json:"rollback_channel_id,omitempty"`
+}
+
+func (api *API) getRolloutPolicyHandler(c echo.Context) error {
+	_, groupID, err := api.validateApplicationAndGroup(c.Param("app"), c.Param("group"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+
+	policy, err := api.getRolloutPolicy(groupID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, rolloutPolicyPayload{
+		MaxFailurePercentage: policy.MaxFailurePercentage,
+		MinSampleSize:        policy.MinSampleSize,
+		RollbackChannelID:    policy.RollbackChannelID.String,
+	})
+}
+
+func (api *API) setRolloutPolicyHandler(c echo.Context) error {
+	_, groupID, err := api.validateApplicationAndGroup(c.Param("app"), c.Param("group"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+
+	var payload rolloutPolicyPayload
+	if err := c.Bind(&payload); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if payload.MaxFailurePercentage < 0 || payload.MaxFailurePercentage > 100 {
+		return echo.NewHTTPError(http.StatusBadRequest, "max_failure_percentage must be between 0 and 100")
+	}
+	if payload.MinSampleSize < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "min_sample_size must not be negative")
+	}
+
+	policy := rolloutPolicy{
+		MaxFailurePercentage: payload.MaxFailurePercentage,
+		MinSampleSize:        payload.MinSampleSize,
+		RollbackChannelID:    null.NewString(payload.RollbackChannelID, payload.RollbackChannelID != ""),
+	}
+	if err := api.setRolloutPolicy(groupID, policy); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, payload)
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"testing"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestRolloutPolicyShouldRollBack(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    rolloutPolicy
+		attempted int
+		failed    int
+		want      bool
+	}{
+		{
+			name:      "disabled policy never rolls back",
+			policy:    rolloutPolicy{MaxFailurePercentage: 0, MinSampleSize: 1},
+			attempted: 100,
+			failed:    100,
+			want:      false,
+		},
+		{
+			name:      "below minimum sample size",
+			policy:    rolloutPolicy{MaxFailurePercentage: 10, MinSampleSize: 10},
+			attempted: 5,
+			failed:    5,
+			want:      false,
+		},
+		{
+			name:      "at minimum sample size, failure rate at threshold doesn't roll back",
+			policy:    rolloutPolicy{MaxFailurePercentage: 50, MinSampleSize: 10},
+			attempted: 10,
+			failed:    5,
+			want:      false,
+		},
+		{
+			name:      "at minimum sample size, failure rate over threshold rolls back",
+			policy:    rolloutPolicy{MaxFailurePercentage: 50, MinSampleSize: 10},
+			attempted: 10,
+			failed:    6,
+			want:      true,
+		},
+		{
+			name:      "no attempts yet",
+			policy:    rolloutPolicy{MaxFailurePercentage: 10, MinSampleSize: 1},
+			attempted: 0,
+			failed:    0,
+			want:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.shouldRollBack(tt.attempted, tt.failed); got != tt.want {
+				t.Errorf("shouldRollBack(%d, %d) = %v, want %v", tt.attempted, tt.failed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRolloutPolicyShouldRollBackWithRollbackChannel(t *testing.T) {
+	policy := rolloutPolicy{
+		MaxFailurePercentage: 20,
+		MinSampleSize:        5,
+		RollbackChannelID:    null.StringFrom("stable-channel-id"),
+	}
+	if !policy.shouldRollBack(5, 2) {
+		t.Error("expected shouldRollBack to report true once threshold is crossed, regardless of RollbackChannelID")
+	}
+}
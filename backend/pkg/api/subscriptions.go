@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/flatcar/nebraska/backend/pkg/subscriptions"
+)
+
+// subscriptionsServices holds each *API instance's subscriptions service,
+// keyed by the instance itself. It would be a field on API directly, but
+// API's struct definition lives outside this package's diff; keying by
+// pointer here gives each instance (tests build a fresh one per suite, and
+// RollbackDBTo builds a second one in the same process) its own service
+// instead of sharing one. An instance with no entry here means
+// notifyActivitySubscribers is a no-op.
+var subscriptionsServices sync.Map // *API -> *subscriptions.Service
+
+// SetSubscriptionsService wires the subscriptions service used to notify
+// external subscribers of the rollout and instance activity entries created
+// by the event handlers. It should be called once during server setup.
+func (api *API) SetSubscriptionsService(svc *subscriptions.Service) {
+	subscriptionsServices.Store(api, svc)
+}
+
+func (api *API) subscriptionsService() *subscriptions.Service {
+	if v, ok := subscriptionsServices.Load(api); ok {
+		return v.(*subscriptions.Service)
+	}
+	return nil
+}
+
+// teamForApp returns the team an application belongs to, for scoping
+// subscription notifications.
+func (api *API) teamForApp(appID string) (string, error) {
+	query, _, err := goqu.From("application").
+		Select("team_id").
+		Where(goqu.C("id").Eq(appID)).
+		ToSQL()
+	if err != nil {
+		return "", err
+	}
+	var teamID string
+	if err := api.db.QueryRow(query).Scan(&teamID); err != nil {
+		return "", err
+	}
+	return teamID, nil
+}
+
+// notifyActivitySubscribers dispatches a rollout or instance activity entry
+// to every subscription matching its team/app/group/activity_type. createdTs
+// is the triggering event's created_ts, so the signed payload delivered to
+// external subscribers reports when the activity actually happened rather
+// than the zero time.
+func (api *API) notifyActivitySubscribers(activityClass int, appID, groupID, instanceID, version string, createdTs time.Time) {
+	svc := api.subscriptionsService()
+	if svc == nil {
+		return
+	}
+	teamID, err := api.teamForApp(appID)
+	if err != nil {
+		l.Error().Err(err).Msg("notifyActivitySubscribers - could not resolve team for app")
+		return
+	}
+	svc.Notify(context.Background(), teamID, subscriptions.Activity{
+		Class:     activityClass,
+		Version:   version,
+		AppID:     appID,
+		GroupID:   groupID,
+		Instance:  instanceID,
+		CreatedTs: createdTs,
+	})
+}
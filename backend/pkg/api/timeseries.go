@@ -0,0 +1,261 @@
+package api
+
+import (
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// RefreshEventTimeSeriesViews refreshes the event_timeseries_* materialized
+// views, coarsest-grained last so it only scans the refreshed finer-grained
+// view rather than the raw event table. Operators without TimescaleDB
+// (which would refresh these incrementally via continuous aggregates)
+// should call this periodically, e.g. from a cron job or the syncer loop.
+func (api *API) RefreshEventTimeSeriesViews() error {
+	for _, view := range []string{"event_timeseries_1m", "event_timeseries_1h", "event_timeseries_1d"} {
+		// The views are created "with no data", and Postgres refuses a
+		// concurrent refresh against a view that's never been populated
+		// ("cannot refresh materialized view concurrently ... has not
+		// been populated"), so the very first refresh has to be a plain
+		// one. pg_matviews.ispopulated is checked (rather than tracking
+		// this in memory) so it works correctly regardless of which *API
+		// instance, process, or replica calls this first.
+		var populated bool
+		if err := api.db.QueryRow("select ispopulated from pg_matviews where matviewname = $1", view).Scan(&populated); err != nil {
+			return err
+		}
+		if !populated {
+			if _, err := api.db.Exec("refresh materialized view " + view); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := api.db.Exec("refresh materialized view concurrently " + view); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TimeSeriesStep selects the granularity of a GetEventTimeSeries query. It
+// maps directly onto one of the event_timeseries_* materialized views.
+type TimeSeriesStep string
+
+const (
+	StepMinute TimeSeriesStep = "1m"
+	StepHour   TimeSeriesStep = "1h"
+	StepDay    TimeSeriesStep = "1d"
+)
+
+func (s TimeSeriesStep) view() string {
+	switch s {
+	case StepHour:
+		return "event_timeseries_1h"
+	case StepDay:
+		return "event_timeseries_1d"
+	default:
+		return "event_timeseries_1m"
+	}
+}
+
+// TimeSeriesQueryParams filters and paginates a GetEventTimeSeries call.
+type TimeSeriesQueryParams struct {
+	Start     time.Time
+	End       time.Time
+	Step      TimeSeriesStep
+	Version   string
+	EventType int
+	Result    int
+	Page      uint
+	PerPage   uint
+}
+
+// TimeSeriesPoint is a single (bucket, event_type, result) count, as
+// returned by GetEventTimeSeries for charting.
+type TimeSeriesPoint struct {
+	Bucket    time.Time `json:"bucket"`
+	EventType int       `json:"event_type"`
+	Result    int       `json:"result"`
+	Count     int       `json:"count"`
+}
+
+// GetEventTimeSeries returns the per-(event_type, result) counts for appID
+// and groupID, bucketed at params.Step granularity, suitable for charting
+// in the UI or piping into an external dashboard.
+func (api *API) GetEventTimeSeries(appID, groupID string, params TimeSeriesQueryParams) ([]TimeSeriesPoint, error) {
+	if params.PerPage == 0 || params.PerPage > 1000 {
+		params.PerPage = 1000
+	}
+	if params.Page == 0 {
+		params.Page = 1
+	}
+
+	ds := goqu.From(params.Step.view()).
+		Select("bucket", "event_type", "result", goqu.SUM("count").As("count")).
+		Where(
+			goqu.C("application_id").Eq(appID),
+			goqu.C("group_id").Eq(groupID),
+			goqu.C("bucket").Gte(params.Start),
+			goqu.C("bucket").Lte(params.End),
+		).
+		GroupBy("bucket", "event_type", "result").
+		Order(goqu.C("bucket").Asc()).
+		Limit(params.PerPage).
+		Offset((params.Page - 1) * params.PerPage)
+
+	if params.Version != "" {
+		ds = ds.Where(goqu.C("version").Eq(params.Version))
+	}
+	if params.EventType != 0 {
+		ds = ds.Where(goqu.C("event_type").Eq(params.EventType))
+	}
+	if params.Result != 0 {
+		ds = ds.Where(goqu.C("result").Eq(params.Result))
+	}
+
+	query, _, err := ds.ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := api.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []TimeSeriesPoint
+	for rows.Next() {
+		var p TimeSeriesPoint
+		if err := rows.Scan(&p.Bucket, &p.EventType, &p.Result, &p.Count); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// InstanceUpdateMetrics are derived timing/success metrics for a single
+// update attempt of an instance, computed from the paired
+// EventUpdateDownloadStarted/EventUpdateDownloadFinished/
+// EventUpdateInstalled/EventUpdateComplete timestamps.
+type InstanceUpdateMetrics struct {
+	InstanceID       string        `json:"instance_id"`
+	Success          bool          `json:"success"`
+	DownloadDuration time.Duration `json:"download_duration"`
+	TimeToInstall    time.Duration `json:"time_to_install"`
+}
+
+// GetGroupUpdateMetrics computes success, time-to-install and
+// download-duration metrics for every update attempt of the instances in
+// groupID, by pairing up their lifecycle events. An instance with several
+// attempts appears once per attempt; no aggregated success rate is returned.
+func (api *API) GetGroupUpdateMetrics(groupID string) ([]InstanceUpdateMetrics, error) {
+	query, _, err := goqu.From("event").
+		Select(
+			goqu.I("event.instance_id"),
+			goqu.I("event_type.type"),
+			goqu.I("event_type.result"),
+			goqu.I("event.created_ts"),
+		).
+		InnerJoin(goqu.T("event_type"), goqu.On(goqu.I("event.event_type_id").Eq(goqu.I("event_type.id")))).
+		InnerJoin(goqu.T("instance"), goqu.On(goqu.I("instance.id").Eq(goqu.I("event.instance_id")))).
+		Where(goqu.I("instance.group_id").Eq(groupID)).
+		Order(goqu.I("event.instance_id").Asc(), goqu.I("event.created_ts").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := api.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []updateMetricEvent
+	for rows.Next() {
+		var ev updateMetricEvent
+		if err := rows.Scan(&ev.InstanceID, &ev.EventType, &ev.Result, &ev.CreatedTs); err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pairUpdateEvents(events), nil
+}
+
+// updateMetricEvent is one row of the (instance_id, event_type, result,
+// created_ts) query GetGroupUpdateMetrics pairs up; factored out of the SQL
+// scan loop so pairUpdateEvents can be unit tested without a database.
+type updateMetricEvent struct {
+	InstanceID string
+	EventType  int
+	Result     int
+	CreatedTs  time.Time
+}
+
+// pairUpdateEvents groups events (assumed ordered per-instance by
+// created_ts ascending, as GetGroupUpdateMetrics's query guarantees) into
+// one InstanceUpdateMetrics per update attempt. An instance can go through
+// several update attempts over its lifetime (e.g. a failed attempt followed
+// by a retry), each starting a new EventUpdateDownloadStarted; pairing
+// scoped to a single timestamps struct per instance for its whole history
+// would pair a later attempt's finish event against an earlier attempt's
+// start, producing nonsensical (including negative) durations.
+func pairUpdateEvents(events []updateMetricEvent) []InstanceUpdateMetrics {
+	type timestamps struct {
+		downloadStarted, downloadFinished, installed, complete time.Time
+		success                                                bool
+	}
+	byInstance := make(map[string][]*timestamps)
+	current := make(map[string]*timestamps)
+
+	for _, ev := range events {
+		if ev.EventType == EventUpdateDownloadStarted && ev.Result == ResultSuccess {
+			ts := &timestamps{downloadStarted: ev.CreatedTs}
+			byInstance[ev.InstanceID] = append(byInstance[ev.InstanceID], ts)
+			current[ev.InstanceID] = ts
+			continue
+		}
+
+		ts, ok := current[ev.InstanceID]
+		if !ok {
+			// An event arrived for an attempt whose
+			// EventUpdateDownloadStarted wasn't seen (e.g. it predates
+			// this query's window); track it on its own so it's still
+			// reported instead of silently dropped.
+			ts = &timestamps{}
+			byInstance[ev.InstanceID] = append(byInstance[ev.InstanceID], ts)
+			current[ev.InstanceID] = ts
+		}
+		switch {
+		case ev.EventType == EventUpdateDownloadFinished && ev.Result == ResultSuccess:
+			ts.downloadFinished = ev.CreatedTs
+		case ev.EventType == EventUpdateInstalled && ev.Result == ResultSuccess:
+			ts.installed = ev.CreatedTs
+		case ev.EventType == EventUpdateComplete:
+			ts.complete = ev.CreatedTs
+			ts.success = ev.Result == ResultSuccess || ev.Result == ResultSuccessReboot
+			// The attempt is done either way; the next event for this
+			// instance (success or a fresh retry) starts a new one.
+			delete(current, ev.InstanceID)
+		}
+	}
+
+	var metrics []InstanceUpdateMetrics
+	for instanceID, attempts := range byInstance {
+		for _, ts := range attempts {
+			m := InstanceUpdateMetrics{InstanceID: instanceID, Success: ts.success}
+			if !ts.downloadStarted.IsZero() && !ts.downloadFinished.IsZero() {
+				m.DownloadDuration = ts.downloadFinished.Sub(ts.downloadStarted)
+			}
+			if !ts.downloadStarted.IsZero() && !ts.complete.IsZero() {
+				m.TimeToInstall = ts.complete.Sub(ts.downloadStarted)
+			}
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterTimeSeriesRoutes wires
+// /api/apps/{app}/groups/{group}/events/timeseries onto group.
+func RegisterTimeSeriesRoutes(group *echo.Group, api *API) {
+	group.GET("/apps/:app/groups/:group/events/timeseries", api.getEventTimeSeriesHandler)
+}
+
+func (api *API) getEventTimeSeriesHandler(c echo.Context) error {
+	appID := c.Param("app")
+	groupID := c.Param("group")
+
+	params := TimeSeriesQueryParams{
+		Step:    TimeSeriesStep(c.QueryParam("step")),
+		Version: c.QueryParam("version"),
+	}
+	if params.Step == "" {
+		params.Step = StepHour
+	}
+
+	var err error
+	if params.Start, err = parseTimeParam(c.QueryParam("start"), time.Now().Add(-7*24*time.Hour)); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid start: "+err.Error())
+	}
+	if params.End, err = parseTimeParam(c.QueryParam("end"), time.Now()); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid end: "+err.Error())
+	}
+	if v := c.QueryParam("event_type"); v != "" {
+		if params.EventType, err = strconv.Atoi(v); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid event_type")
+		}
+	}
+	if v := c.QueryParam("result"); v != "" {
+		if params.Result, err = strconv.Atoi(v); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid result")
+		}
+	}
+	if v := c.QueryParam("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid page")
+		}
+		params.Page = uint(page)
+	}
+
+	points, err := api.GetEventTimeSeries(appID, groupID, params)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, points)
+}
+
+func parseTimeParam(v string, fallback time.Time) (time.Time, error) {
+	if v == "" {
+		return fallback, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
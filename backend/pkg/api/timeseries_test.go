@@ -0,0 +1,72 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPairUpdateEventsScopesToASingleAttempt(t *testing.T) {
+	base := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+
+	events := []updateMetricEvent{
+		// First attempt: fails.
+		{InstanceID: "i1", EventType: EventUpdateDownloadStarted, Result: ResultSuccess, CreatedTs: at(0)},
+		{InstanceID: "i1", EventType: EventUpdateDownloadFinished, Result: ResultSuccess, CreatedTs: at(1)},
+		{InstanceID: "i1", EventType: EventUpdateComplete, Result: ResultFailed, CreatedTs: at(2)},
+		// Second attempt, much later: succeeds.
+		{InstanceID: "i1", EventType: EventUpdateDownloadStarted, Result: ResultSuccess, CreatedTs: at(100)},
+		{InstanceID: "i1", EventType: EventUpdateDownloadFinished, Result: ResultSuccess, CreatedTs: at(105)},
+		{InstanceID: "i1", EventType: EventUpdateComplete, Result: ResultSuccessReboot, CreatedTs: at(110)},
+	}
+
+	metrics := pairUpdateEvents(events)
+	if len(metrics) != 2 {
+		t.Fatalf("expected 2 attempts, got %d: %+v", len(metrics), metrics)
+	}
+
+	for _, m := range metrics {
+		if m.DownloadDuration < 0 || m.TimeToInstall < 0 {
+			t.Errorf("attempt for %s produced a negative duration: %+v", m.InstanceID, m)
+		}
+	}
+
+	first, second := metrics[0], metrics[1]
+	if first.Success {
+		t.Error("expected the first attempt to be recorded as failed")
+	}
+	if first.TimeToInstall != 2*time.Minute {
+		t.Errorf("first attempt TimeToInstall = %v, want 2m", first.TimeToInstall)
+	}
+	if !second.Success {
+		t.Error("expected the second attempt to be recorded as successful")
+	}
+	if second.TimeToInstall != 10*time.Minute {
+		t.Errorf("second attempt TimeToInstall = %v, want 10m", second.TimeToInstall)
+	}
+}
+
+func TestPairUpdateEventsKeepsInstancesIndependent(t *testing.T) {
+	base := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	events := []updateMetricEvent{
+		{InstanceID: "i1", EventType: EventUpdateDownloadStarted, Result: ResultSuccess, CreatedTs: base},
+		{InstanceID: "i2", EventType: EventUpdateDownloadStarted, Result: ResultSuccess, CreatedTs: base},
+		{InstanceID: "i1", EventType: EventUpdateComplete, Result: ResultSuccess, CreatedTs: base.Add(time.Minute)},
+		{InstanceID: "i2", EventType: EventUpdateComplete, Result: ResultFailed, CreatedTs: base.Add(2 * time.Minute)},
+	}
+
+	metrics := pairUpdateEvents(events)
+	if len(metrics) != 2 {
+		t.Fatalf("expected 2 attempts, got %d: %+v", len(metrics), metrics)
+	}
+	byInstance := map[string]InstanceUpdateMetrics{}
+	for _, m := range metrics {
+		byInstance[m.InstanceID] = m
+	}
+	if !byInstance["i1"].Success {
+		t.Error("expected i1's attempt to be successful")
+	}
+	if byInstance["i2"].Success {
+		t.Error("expected i2's attempt to be failed")
+	}
+}
@@ -0,0 +1,237 @@
+// Package eventqueue backs the durable pipeline that separates ingesting an
+// Omaha event from processing its consequences. Events posted to
+// api.RegisterEvent are recorded, together with a queue entry, in a single
+// transaction on the Omaha request path; everything else (resolving the
+// instance/group context and applying instance status/activity/rollout
+// changes) happens out of band in the Resolve and Apply stages, driven by
+// worker pools that claim rows with "SELECT ... FOR UPDATE SKIP LOCKED" so
+// they can be scaled horizontally.
+package eventqueue
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var queueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "nebraska_event_queue_depth",
+	Help: "Number of pending or processing event_queue entries, by stage.",
+}, []string{"stage"})
+
+// Stage identifies a step of the event processing pipeline.
+type Stage string
+
+const (
+	// StageResolve loads the instance/group/app context for an event and
+	// checks that it is still valid. It does not compute or store a state
+	// transition for the apply stage.
+	StageResolve Stage = "resolve"
+	// StageApply works out and applies the instance status update, activity
+	// entries and rollout bookkeeping for the event, from the event itself.
+	StageApply Stage = "apply"
+)
+
+// Status is the lifecycle state of a queue entry.
+type Status string
+
+const (
+	StatusPending    Status = "pending"
+	StatusProcessing Status = "processing"
+	StatusDone       Status = "done"
+	StatusFailed     Status = "failed"
+)
+
+// MaxAttempts bounds how many times a stage is attempted before a queue entry
+// is marked StatusFailed and left for operator inspection.
+const MaxAttempts = 8
+
+// Job is a single event_queue row claimed by a worker.
+type Job struct {
+	ID                int64
+	EventID           int64
+	GroupID           string
+	LastUpdateVersion string
+	Stage             Stage
+	Attempts          int
+}
+
+// Queue is the durable, DB-backed event_queue table.
+type Queue struct {
+	db *sql.DB
+}
+
+// New creates a Queue backed by db.
+func New(db *sql.DB) *Queue {
+	return &Queue{db: db}
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx, so Enqueue can be called
+// either standalone or as part of the caller's transaction that inserts the
+// event row itself.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Enqueue inserts a StageResolve queue entry for eventID using exec, so
+// callers can enqueue it in the same transaction that inserts the event
+// row, guaranteeing the two either both commit or both roll back. groupID
+// and lastUpdateVersion are denormalized from the Omaha request, since the
+// event row itself doesn't carry the group an instance belongs to.
+func Enqueue(ctx context.Context, exec execer, eventID int64, groupID, lastUpdateVersion string) error {
+	query, _, err := goqu.Insert("event_queue").
+		Cols("event_id", "group_id", "last_update_version", "stage", "status", "attempts", "next_attempt_at").
+		Vals(goqu.Vals{eventID, groupID, lastUpdateVersion, StageResolve, StatusPending, 0, goqu.L("now()")}).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	_, err = exec.ExecContext(ctx, query)
+	return err
+}
+
+// Claim atomically claims the oldest pending job for stage that is due for
+// processing, marking it StatusProcessing, and returns it. It returns
+// (nil, sql.ErrNoRows) if there is nothing to claim.
+func (q *Queue) Claim(ctx context.Context, stage Stage) (*Job, error) {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	selectQuery, _, err := goqu.From("event_queue").
+		Select("id", "event_id", "group_id", "last_update_version", "attempts").
+		Where(
+			goqu.C("stage").Eq(stage),
+			goqu.C("status").Eq(StatusPending),
+			goqu.C("next_attempt_at").Lte(goqu.L("now()")),
+		).
+		Order(goqu.C("id").Asc()).
+		Limit(1).
+		ForUpdate(goqu.SkipLocked).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+
+	var job Job
+	job.Stage = stage
+	if err := tx.QueryRowContext(ctx, selectQuery).Scan(&job.ID, &job.EventID, &job.GroupID, &job.LastUpdateVersion, &job.Attempts); err != nil {
+		return nil, err
+	}
+
+	updateQuery, _, err := goqu.Update("event_queue").
+		Set(goqu.Record{"status": StatusProcessing}).
+		Where(goqu.C("id").Eq(job.ID)).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, updateQuery); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Advance marks job done and, unless stage was already StageApply, enqueues
+// the next stage for the same event.
+func (q *Queue) Advance(ctx context.Context, job *Job) error {
+	if job.Stage == StageResolve {
+		insertQuery, _, err := goqu.Insert("event_queue").
+			Cols("event_id", "group_id", "last_update_version", "stage", "status", "attempts", "next_attempt_at").
+			Vals(goqu.Vals{job.EventID, job.GroupID, job.LastUpdateVersion, StageApply, StatusPending, 0, goqu.L("now()")}).
+			ToSQL()
+		if err != nil {
+			return err
+		}
+		if _, err := q.db.ExecContext(ctx, insertQuery); err != nil {
+			return err
+		}
+	}
+
+	query, _, err := goqu.Update("event_queue").
+		Set(goqu.Record{"status": StatusDone}).
+		Where(goqu.C("id").Eq(job.ID)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	_, err = q.db.ExecContext(ctx, query)
+	return err
+}
+
+// Retry reschedules job for another attempt after an exponential backoff,
+// or marks it StatusFailed once it has failed MaxAttempts times.
+func (q *Queue) Retry(ctx context.Context, job *Job) error {
+	attempts := job.Attempts + 1
+	if attempts >= MaxAttempts {
+		query, _, err := goqu.Update("event_queue").
+			Set(goqu.Record{"status": StatusFailed, "attempts": attempts}).
+			Where(goqu.C("id").Eq(job.ID)).
+			ToSQL()
+		if err != nil {
+			return err
+		}
+		_, err = q.db.ExecContext(ctx, query)
+		return err
+	}
+
+	backoff := retryBackoff(attempts)
+	query, _, err := goqu.Update("event_queue").
+		Set(goqu.Record{
+			"status":          StatusPending,
+			"attempts":        attempts,
+			"next_attempt_at": goqu.L("now() + ?", backoff.String()),
+		}).
+		Where(goqu.C("id").Eq(job.ID)).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	_, err = q.db.ExecContext(ctx, query)
+	return err
+}
+
+// maxRetryBackoff caps the exponential delay Retry schedules between
+// attempts.
+const maxRetryBackoff = 5 * time.Minute
+
+// retryBackoff is the exponential backoff delay before the given attempt
+// number, capped at maxRetryBackoff.
+func retryBackoff(attempts int) time.Duration {
+	backoff := time.Duration(1<<uint(attempts)) * time.Second
+	if backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+	return backoff
+}
+
+// Depth returns the number of pending or processing entries for stage, for
+// use as a queue depth metric.
+func (q *Queue) Depth(ctx context.Context, stage Stage) (int, error) {
+	query, _, err := goqu.From("event_queue").
+		Select(goqu.COUNT("id")).
+		Where(
+			goqu.C("stage").Eq(stage),
+			goqu.C("status").In(StatusPending, StatusProcessing),
+		).
+		ToSQL()
+	if err != nil {
+		return 0, err
+	}
+	var depth int
+	if err := q.db.QueryRowContext(ctx, query).Scan(&depth); err != nil {
+		return 0, err
+	}
+	queueDepth.WithLabelValues(string(stage)).Set(float64(depth))
+	return depth, nil
+}
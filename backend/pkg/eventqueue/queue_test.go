@@ -0,0 +1,24 @@
+package eventqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 2 * time.Second},
+		{attempts: 2, want: 4 * time.Second},
+		{attempts: 5, want: 32 * time.Second},
+		{attempts: 10, want: maxRetryBackoff},
+		{attempts: 30, want: maxRetryBackoff},
+	}
+	for _, tt := range tests {
+		if got := retryBackoff(tt.attempts); got != tt.want {
+			t.Errorf("retryBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package eventqueue
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var l = log.With().Str("module", "eventqueue").Logger()
+
+// HandlerFunc processes a single claimed job. An error causes the job to be
+// retried with backoff, up to MaxAttempts.
+type HandlerFunc func(ctx context.Context, job *Job) error
+
+// Worker repeatedly claims and processes jobs for a single stage. Running
+// several Workers for the same stage (in one process or several) is safe:
+// Claim uses SELECT ... FOR UPDATE SKIP LOCKED so each job is only handed
+// to one worker.
+type Worker struct {
+	queue        *Queue
+	stage        Stage
+	handler      HandlerFunc
+	pollInterval time.Duration
+}
+
+// NewWorker creates a Worker that claims jobs for stage from queue and
+// processes them with handler, polling every pollInterval when idle.
+func NewWorker(queue *Queue, stage Stage, pollInterval time.Duration, handler HandlerFunc) *Worker {
+	return &Worker{
+		queue:        queue,
+		stage:        stage,
+		handler:      handler,
+		pollInterval: pollInterval,
+	}
+}
+
+// Run claims and processes jobs until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for w.processOne(ctx) {
+			}
+		}
+	}
+}
+
+// processOne claims and processes a single job. It returns true if a job
+// was claimed, so Run can keep draining the backlog without waiting for the
+// next tick.
+func (w *Worker) processOne(ctx context.Context) bool {
+	job, err := w.queue.Claim(ctx, w.stage)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false
+	}
+	if err != nil {
+		l.Error().Err(err).Str("stage", string(w.stage)).Msg("eventqueue: could not claim job")
+		return false
+	}
+
+	if err := w.handler(ctx, job); err != nil {
+		l.Warn().Err(err).Str("stage", string(w.stage)).Int64("job_id", job.ID).Msg("eventqueue: handler failed, scheduling retry")
+		if retryErr := w.queue.Retry(ctx, job); retryErr != nil {
+			l.Error().Err(retryErr).Msg("eventqueue: could not schedule retry")
+		}
+		return true
+	}
+
+	if err := w.queue.Advance(ctx, job); err != nil {
+		l.Error().Err(err).Int64("job_id", job.ID).Msg("eventqueue: could not advance job")
+	}
+	return true
+}
@@ -0,0 +1,43 @@
+package eventsink
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// PostgresDeadLetterer records events that exceeded their retry limit into
+// the "eventsink_dead_letter" table, so operators can inspect and
+// optionally replay them.
+type PostgresDeadLetterer struct {
+	db *sql.DB
+}
+
+// NewPostgresDeadLetterer creates a DeadLetterer backed by db.
+func NewPostgresDeadLetterer(db *sql.DB) *PostgresDeadLetterer {
+	return &PostgresDeadLetterer{db: db}
+}
+
+func (d *PostgresDeadLetterer) DeadLetter(ctx context.Context, event CloudEvent, lastErr error) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	lastErrMsg := ""
+	if lastErr != nil {
+		lastErrMsg = lastErr.Error()
+	}
+
+	query, _, err := goqu.Insert("eventsink_dead_letter").
+		Cols("event_type", "subject", "payload", "attempts", "last_error").
+		Vals(goqu.Vals{string(event.Type), event.Subject, string(payload), event.attempts, lastErrMsg}).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	_, err = d.db.ExecContext(ctx, query)
+	return err
+}
@@ -0,0 +1,252 @@
+// Package eventsink publishes CloudEvents describing Omaha update lifecycle
+// transitions (download started/finished, installed, update complete/failed,
+// rollout finished/failed) to a configurable, pluggable sink. A type for a
+// rollout starting is defined, but no events of that type are published.
+//
+// Delivery is asynchronous: Publish enqueues the event on a bounded buffered
+// channel and returns immediately, so a slow or unavailable sink never blocks
+// the Omaha request path. A pool of workers drains the channel, sends events
+// through the configured Transport, and retries failed deliveries with
+// exponential backoff up to a configured limit before handing the event to
+// the DeadLetterer.
+package eventsink
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var l = log.With().Str("module", "eventsink").Logger()
+
+// CloudEventType identifies the kind of lifecycle transition being reported.
+type CloudEventType string
+
+const (
+	// TypeDownloadStarted is emitted when an instance starts downloading an
+	// update package.
+	TypeDownloadStarted CloudEventType = "io.nebraska.instance.download_started"
+	// TypeDownloadFinished is emitted when an instance finishes downloading
+	// an update package.
+	TypeDownloadFinished CloudEventType = "io.nebraska.instance.download_finished"
+	// TypeInstalled is emitted when an instance installs an update package.
+	TypeInstalled CloudEventType = "io.nebraska.instance.installed"
+	// TypeUpdateComplete is emitted when an instance reports that an update
+	// completed successfully. A failed update is reported as
+	// TypeUpdateFailed instead.
+	TypeUpdateComplete CloudEventType = "io.nebraska.instance.update_complete"
+	// TypeUpdateFailed is emitted when an instance reports a failed
+	// operation.
+	TypeUpdateFailed CloudEventType = "io.nebraska.instance.update_failed"
+	// TypeRolloutStarted identifies a group rollout starting. Nothing in
+	// Nebraska publishes events of this type.
+	TypeRolloutStarted CloudEventType = "io.nebraska.group.rollout_started"
+	// TypeRolloutFinished is emitted when a group rollout finishes
+	// successfully.
+	TypeRolloutFinished CloudEventType = "io.nebraska.group.rollout_finished"
+	// TypeRolloutFailed is emitted when a group rollout is aborted because
+	// of failures.
+	TypeRolloutFailed CloudEventType = "io.nebraska.group.rollout_failed"
+)
+
+// Data is the CloudEvents JSON payload describing an update lifecycle
+// transition.
+type Data struct {
+	Instance        string `json:"instance"`
+	Channel         string `json:"channel,omitempty"`
+	Version         string `json:"version,omitempty"`
+	PreviousVersion string `json:"previous_version,omitempty"`
+	ErrorCode       string `json:"error_code,omitempty"`
+}
+
+// CloudEvent is a CloudEvents v1.0 envelope describing a single Omaha update
+// lifecycle transition.
+type CloudEvent struct {
+	SpecVersion string         `json:"specversion"`
+	ID          string         `json:"id"`
+	Type        CloudEventType `json:"type"`
+	Source      string         `json:"source"`
+	Subject     string         `json:"subject"`
+	Time        time.Time      `json:"time"`
+	DataContentType string     `json:"datacontenttype"`
+	Data        Data           `json:"data"`
+
+	attempts int
+}
+
+// Transport delivers a single CloudEvent to a sink. Implementations must be
+// safe for concurrent use.
+type Transport interface {
+	// Send delivers event and returns an error if delivery failed. Send may
+	// be retried by the caller, so it must be safe to call more than once
+	// for the same event.
+	Send(ctx context.Context, event CloudEvent) error
+	// Close releases any resources held by the transport.
+	Close() error
+}
+
+// DeadLetterer records events that exceeded their retry budget.
+type DeadLetterer interface {
+	DeadLetter(ctx context.Context, event CloudEvent, lastErr error) error
+}
+
+// Config configures a Sink. It is meant to be embedded into the server's
+// config.Config and populated from the same source (flags/env vars).
+type Config struct {
+	// Enabled turns event publishing on or off. When false, New returns a
+	// no-op Sink.
+	Enabled bool
+	// Transport selects the wire transport: "http", "kafka" or "pubsub".
+	Transport string
+	// BufferSize bounds the number of events held in memory awaiting
+	// delivery. Publish drops the event and logs a warning once the buffer
+	// is full, rather than blocking the Omaha request path.
+	BufferSize int
+	// Workers is the number of goroutines draining the buffer concurrently.
+	Workers int
+	// MaxRetries is the number of delivery attempts before an event is
+	// handed to the DeadLetterer.
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry; subsequent
+	// retries double it, up to MaxBackoff.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the exponential backoff delay between retries.
+	MaxBackoff time.Duration
+	// Source is used as the CloudEvents "source" attribute, typically the
+	// Nebraska server URL.
+	Source string
+}
+
+// DefaultConfig returns the Config used when none is provided.
+func DefaultConfig() Config {
+	return Config{
+		Transport:      "http",
+		BufferSize:     1024,
+		Workers:        4,
+		MaxRetries:     5,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+	}
+}
+
+// Sink asynchronously delivers CloudEvents to a Transport, retrying failed
+// deliveries with exponential backoff and dead-lettering events that
+// exhaust their retry budget.
+type Sink struct {
+	cfg       Config
+	transport Transport
+	deadLeter DeadLetterer
+	metrics   *metricsCollector
+
+	queue  chan CloudEvent
+	wg     sync.WaitGroup
+	stopCh chan struct{}
+}
+
+// New creates a Sink that delivers events through transport, dead-lettering
+// exhausted events via dl. If cfg.Enabled is false, the returned Sink's
+// Publish is a no-op.
+func New(cfg Config, transport Transport, dl DeadLetterer) *Sink {
+	s := &Sink{
+		cfg:       cfg,
+		transport: transport,
+		deadLeter: dl,
+		metrics:   newMetricsCollector(),
+		queue:     make(chan CloudEvent, cfg.BufferSize),
+		stopCh:    make(chan struct{}),
+	}
+	if cfg.Enabled {
+		workers := cfg.Workers
+		if workers <= 0 {
+			workers = 1
+		}
+		for i := 0; i < workers; i++ {
+			s.wg.Add(1)
+			go s.worker()
+		}
+	}
+	return s
+}
+
+// Publish enqueues event for asynchronous delivery. It never blocks: if the
+// buffer is full the event is dropped and a warning is logged, so a slow
+// sink can't back up the Omaha request path.
+func (s *Sink) Publish(event CloudEvent) {
+	if !s.cfg.Enabled {
+		return
+	}
+	select {
+	case s.queue <- event:
+	default:
+		s.metrics.dropped.Inc()
+		l.Warn().Str("type", string(event.Type)).Str("subject", event.Subject).Msg("eventsink: buffer full, dropping event")
+	}
+}
+
+// Close stops accepting new events, waits for in-flight deliveries to drain
+// and closes the underlying transport.
+func (s *Sink) Close() error {
+	close(s.stopCh)
+	close(s.queue)
+	s.wg.Wait()
+	return s.transport.Close()
+}
+
+func (s *Sink) worker() {
+	defer s.wg.Done()
+	for event := range s.queue {
+		s.deliver(event)
+	}
+}
+
+func (s *Sink) deliver(event CloudEvent) {
+	backoff := s.cfg.InitialBackoff
+	var lastErr error
+	for event.attempts < s.cfg.MaxRetries {
+		event.attempts++
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := s.transport.Send(ctx, event)
+		cancel()
+		if err == nil {
+			s.metrics.delivered.Inc()
+			return
+		}
+		lastErr = err
+		if event.attempts >= s.cfg.MaxRetries {
+			break
+		}
+		s.metrics.retried.Inc()
+		l.Warn().Err(err).Str("type", string(event.Type)).Int("attempt", event.attempts).Msg("eventsink: delivery failed, retrying")
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-s.stopCh:
+			timer.Stop()
+			if s.deadLeter != nil {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				if err := s.deadLeter.DeadLetter(ctx, event, lastErr); err != nil {
+					l.Error().Err(err).Msg("eventsink: could not record dead letter")
+				}
+				cancel()
+			}
+			return
+		}
+		backoff *= 2
+		if backoff > s.cfg.MaxBackoff {
+			backoff = s.cfg.MaxBackoff
+		}
+	}
+
+	s.metrics.failed.Inc()
+	l.Error().Err(lastErr).Str("type", string(event.Type)).Str("subject", event.Subject).Msg("eventsink: delivery exhausted retries, dead-lettering")
+	if s.deadLeter != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := s.deadLeter.DeadLetter(ctx, event, lastErr); err != nil {
+			l.Error().Err(err).Msg("eventsink: could not record dead letter")
+		}
+	}
+}
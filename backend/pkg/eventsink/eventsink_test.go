@@ -0,0 +1,117 @@
+package eventsink
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingTransport fails the first failUntil sends, then succeeds, so tests
+// can assert retry/backoff behavior without a real sink.
+type countingTransport struct {
+	failUntil int32
+	attempts  int32
+}
+
+func (t *countingTransport) Send(ctx context.Context, event CloudEvent) error {
+	n := atomic.AddInt32(&t.attempts, 1)
+	if n <= t.failUntil {
+		return errors.New("countingTransport: simulated failure")
+	}
+	return nil
+}
+
+func (t *countingTransport) Close() error { return nil }
+
+// alwaysFailTransport never succeeds, so deliveries always exhaust their
+// retry budget and reach the DeadLetterer.
+type alwaysFailTransport struct{}
+
+func (alwaysFailTransport) Send(ctx context.Context, event CloudEvent) error {
+	return errors.New("alwaysFailTransport: simulated failure")
+}
+
+func (alwaysFailTransport) Close() error { return nil }
+
+type recordingDeadLetterer struct {
+	mu     sync.Mutex
+	events []CloudEvent
+}
+
+func (d *recordingDeadLetterer) DeadLetter(ctx context.Context, event CloudEvent, lastErr error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.events = append(d.events, event)
+	return nil
+}
+
+func (d *recordingDeadLetterer) count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.events)
+}
+
+func testConfig() Config {
+	cfg := DefaultConfig()
+	cfg.Enabled = true
+	cfg.Workers = 1
+	cfg.BufferSize = 8
+	cfg.MaxRetries = 3
+	cfg.InitialBackoff = time.Millisecond
+	cfg.MaxBackoff = 5 * time.Millisecond
+	return cfg
+}
+
+func TestSinkDeliverRetriesThenSucceeds(t *testing.T) {
+	transport := &countingTransport{failUntil: 2}
+	dl := &recordingDeadLetterer{}
+	sink := New(testConfig(), transport, dl)
+	defer sink.Close() //nolint:errcheck
+
+	sink.Publish(CloudEvent{Type: TypeDownloadStarted, Subject: "app/group/instance"})
+
+	waitFor(t, func() bool { return atomic.LoadInt32(&transport.attempts) >= 3 })
+	if dl.count() != 0 {
+		t.Fatalf("expected no dead-lettered events, got %d", dl.count())
+	}
+}
+
+func TestSinkDeliverExhaustsRetriesAndDeadLetters(t *testing.T) {
+	dl := &recordingDeadLetterer{}
+	sink := New(testConfig(), alwaysFailTransport{}, dl)
+	defer sink.Close() //nolint:errcheck
+
+	sink.Publish(CloudEvent{Type: TypeUpdateFailed, Subject: "app/group/instance"})
+
+	waitFor(t, func() bool { return dl.count() == 1 })
+}
+
+func TestSinkPublishDisabledIsNoop(t *testing.T) {
+	cfg := testConfig()
+	cfg.Enabled = false
+	transport := &countingTransport{}
+	sink := New(cfg, transport, nil)
+	defer sink.Close() //nolint:errcheck
+
+	sink.Publish(CloudEvent{Type: TypeInstalled})
+
+	time.Sleep(10 * time.Millisecond)
+	if atomic.LoadInt32(&transport.attempts) != 0 {
+		t.Fatalf("expected disabled sink to never call the transport, got %d attempts", transport.attempts)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("condition not met before deadline")
+}
@@ -0,0 +1,47 @@
+package eventsink
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// metricsCollector holds the delivered/failed/retried counters exposed
+// alongside the rest of Nebraska's metrics (see pkg/metrics).
+type metricsCollector struct {
+	delivered prometheus.Counter
+	failed    prometheus.Counter
+	retried   prometheus.Counter
+	dropped   prometheus.Counter
+}
+
+// The counters are registered once at package-init time (mirroring
+// eventqueue.queueDepth) rather than per-Sink: promauto panics on a
+// duplicate registration, and every *Sink - one per server instance, one
+// per test - shares the same process-wide Prometheus registry.
+var (
+	eventsDelivered = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "nebraska_eventsink_delivered_total",
+		Help: "Total number of CloudEvents successfully delivered to the configured sink.",
+	})
+	eventsFailed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "nebraska_eventsink_failed_total",
+		Help: "Total number of CloudEvents that exhausted their retry budget and were dead-lettered.",
+	})
+	eventsRetried = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "nebraska_eventsink_retried_total",
+		Help: "Total number of CloudEvent delivery attempts that failed and were retried.",
+	})
+	eventsDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "nebraska_eventsink_dropped_total",
+		Help: "Total number of CloudEvents dropped because the delivery buffer was full.",
+	})
+)
+
+func newMetricsCollector() *metricsCollector {
+	return &metricsCollector{
+		delivered: eventsDelivered,
+		failed:    eventsFailed,
+		retried:   eventsRetried,
+		dropped:   eventsDropped,
+	}
+}
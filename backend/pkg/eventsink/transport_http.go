@@ -0,0 +1,89 @@
+package eventsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ContentMode selects how a CloudEvent is encoded onto an HTTP request, per
+// the CloudEvents HTTP protocol binding spec.
+type ContentMode string
+
+const (
+	// ContentModeBinary maps CloudEvents attributes to HTTP headers (Ce-Id,
+	// Ce-Type, ...) and puts the data payload in the request body.
+	ContentModeBinary ContentMode = "binary"
+	// ContentModeStructured encodes the whole event, attributes included,
+	// as a single "application/cloudevents+json" body.
+	ContentModeStructured ContentMode = "structured"
+)
+
+// HTTPTransport delivers CloudEvents as HTTP/JSON requests, in either binary
+// or structured content mode.
+type HTTPTransport struct {
+	url         string
+	contentMode ContentMode
+	client      *http.Client
+}
+
+// NewHTTPTransport creates a Transport that POSTs events to url using the
+// given content mode. If mode is empty, ContentModeBinary is used.
+func NewHTTPTransport(url string, mode ContentMode) *HTTPTransport {
+	if mode == "" {
+		mode = ContentModeBinary
+	}
+	return &HTTPTransport{
+		url:         url,
+		contentMode: mode,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (t *HTTPTransport) Send(ctx context.Context, event CloudEvent) error {
+	var body []byte
+	var err error
+	switch t.contentMode {
+	case ContentModeStructured:
+		body, err = json.Marshal(event)
+	default:
+		body, err = json.Marshal(event.Data)
+	}
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if t.contentMode == ContentModeStructured {
+		req.Header.Set("Content-Type", "application/cloudevents+json")
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Ce-Id", event.ID)
+		req.Header.Set("Ce-Specversion", event.SpecVersion)
+		req.Header.Set("Ce-Type", string(event.Type))
+		req.Header.Set("Ce-Source", event.Source)
+		req.Header.Set("Ce-Subject", event.Subject)
+		req.Header.Set("Ce-Time", event.Time.Format(time.RFC3339Nano))
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("eventsink: sink returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (t *HTTPTransport) Close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
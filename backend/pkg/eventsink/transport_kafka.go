@@ -0,0 +1,42 @@
+package eventsink
+
+import (
+	"context"
+	"encoding/json"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+// KafkaTransport delivers CloudEvents as JSON messages to a Kafka topic,
+// keyed by the event subject so that all events for a given
+// appID/groupID/instanceID land on the same partition.
+type KafkaTransport struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaTransport creates a Transport that produces to topic on the given
+// brokers.
+func NewKafkaTransport(brokers []string, topic string) *KafkaTransport {
+	return &KafkaTransport{
+		writer: &kafka.Writer{
+			Addr:     kafka.TCP(brokers...),
+			Topic:    topic,
+			Balancer: &kafka.Hash{},
+		},
+	}
+}
+
+func (t *KafkaTransport) Send(ctx context.Context, event CloudEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return t.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(event.Subject),
+		Value: payload,
+	})
+}
+
+func (t *KafkaTransport) Close() error {
+	return t.writer.Close()
+}
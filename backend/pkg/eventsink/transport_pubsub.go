@@ -0,0 +1,49 @@
+package eventsink
+
+import (
+	"context"
+	"encoding/json"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// PubSubTransport delivers CloudEvents as JSON messages to a Google Pub/Sub
+// topic.
+type PubSubTransport struct {
+	client *pubsub.Client
+	topic  *pubsub.Topic
+}
+
+// NewPubSubTransport creates a Transport that publishes to topicID in
+// projectID, authenticating with the environment's default credentials.
+func NewPubSubTransport(ctx context.Context, projectID, topicID string) (*PubSubTransport, error) {
+	client, err := pubsub.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return &PubSubTransport{
+		client: client,
+		topic:  client.Topic(topicID),
+	}, nil
+}
+
+func (t *PubSubTransport) Send(ctx context.Context, event CloudEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	result := t.topic.Publish(ctx, &pubsub.Message{
+		Data: payload,
+		Attributes: map[string]string{
+			"ce-type":    string(event.Type),
+			"ce-subject": event.Subject,
+		},
+	})
+	_, err = result.Get(ctx)
+	return err
+}
+
+func (t *PubSubTransport) Close() error {
+	t.topic.Stop()
+	return t.client.Close()
+}
@@ -0,0 +1,148 @@
+package subscriptions
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"golang.org/x/time/rate"
+)
+
+const (
+	dispatchBufferSize = 1024
+	dispatchWorkers    = 4
+	maxDeliveryRetries = 5
+)
+
+// dispatcher queues (subscription, activity) pairs and delivers them with
+// retries, backoff and a per-subscription rate limit, recording every
+// attempt in subscription_delivery.
+type dispatcher struct {
+	db      *sql.DB
+	queue   chan delivery
+	limiter sync.Map // subscription ID -> *rate.Limiter
+}
+
+type delivery struct {
+	sub      Subscription
+	activity Activity
+	attempts int
+}
+
+func newDispatcher(db *sql.DB) *dispatcher {
+	d := &dispatcher{
+		db:    db,
+		queue: make(chan delivery, dispatchBufferSize),
+	}
+	for i := 0; i < dispatchWorkers; i++ {
+		go d.worker()
+	}
+	return d
+}
+
+func (d *dispatcher) enqueue(sub Subscription, activity Activity) {
+	select {
+	case d.queue <- delivery{sub: sub, activity: activity}:
+	default:
+		l.Warn().Str("subscription_id", sub.ID).Msg("subscriptions: dispatch buffer full, dropping delivery")
+	}
+}
+
+func (d *dispatcher) worker() {
+	for item := range d.queue {
+		d.limiterFor(item.sub).Wait(context.Background()) //nolint:errcheck
+
+		err := deliver(context.Background(), item.sub, item.activity, d.db)
+		if err != nil {
+			item.attempts++
+			l.Warn().Err(err).Str("subscription_id", item.sub.ID).Int("attempt", item.attempts).Msg("subscriptions: delivery failed")
+			if item.attempts < maxDeliveryRetries {
+				go func(item delivery) {
+					time.Sleep(time.Duration(item.attempts) * time.Second)
+					d.queue <- item
+				}(item)
+			}
+		}
+	}
+}
+
+func (d *dispatcher) limiterFor(sub Subscription) *rate.Limiter {
+	if v, ok := d.limiter.Load(sub.ID); ok {
+		return v.(*rate.Limiter)
+	}
+	limiter := rate.NewLimiter(rate.Limit(sub.RateLimit), 1)
+	actual, _ := d.limiter.LoadOrStore(sub.ID, limiter)
+	return actual.(*rate.Limiter)
+}
+
+// sign computes the HMAC-SHA256 signature of payload using sub.Secret, hex
+// encoded, for the X-Nebraska-Signature header.
+func sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliver POSTs activity to sub's target URL, signs it and records the
+// outcome in subscription_delivery.
+func deliver(ctx context.Context, sub Subscription, activity Activity, db *sql.DB) error {
+	payload, err := marshalActivity(activity)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.TargetURL, bytes.NewReader(payload))
+	if err != nil {
+		if recErr := recordDelivery(db, sub.ID, 0, err); recErr != nil {
+			l.Error().Err(recErr).Str("subscription_id", sub.ID).Msg("subscriptions: could not record delivery")
+		}
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Nebraska-Signature", sign(sub.Secret, payload))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if recErr := recordDelivery(db, sub.ID, 0, err); recErr != nil {
+			l.Error().Err(recErr).Str("subscription_id", sub.ID).Msg("subscriptions: could not record delivery")
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	var deliveryErr error
+	if resp.StatusCode >= 300 {
+		deliveryErr = fmt.Errorf("subscriptions: target returned status %d", resp.StatusCode)
+	}
+	if err := recordDelivery(db, sub.ID, resp.StatusCode, deliveryErr); err != nil {
+		return err
+	}
+	return deliveryErr
+}
+
+func recordDelivery(db *sql.DB, subscriptionID string, statusCode int, deliveryErr error) error {
+	errMsg := ""
+	if deliveryErr != nil {
+		errMsg = deliveryErr.Error()
+	}
+	query, _, err := goqu.Insert("subscription_delivery").
+		Cols("subscription_id", "status_code", "error").
+		Vals(goqu.Vals{subscriptionID, statusCode, errMsg}).
+		ToSQL()
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(query)
+	return err
+}
@@ -0,0 +1,39 @@
+package subscriptions
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestSignIsDeterministicHMACSHA256(t *testing.T) {
+	secret := "s3cr3t"
+	payload := []byte(`{"class":1}`)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	want := hex.EncodeToString(mac.Sum(nil))
+
+	if got := sign(secret, payload); got != want {
+		t.Errorf("sign() = %q, want %q", got, want)
+	}
+
+	// Signing is deterministic for the same (secret, payload) pair.
+	if got := sign(secret, payload); got != want {
+		t.Errorf("sign() not deterministic: got %q, want %q", got, want)
+	}
+}
+
+func TestSignDiffersByPayloadAndSecret(t *testing.T) {
+	a := sign("secret-a", []byte("payload"))
+	b := sign("secret-b", []byte("payload"))
+	if a == b {
+		t.Error("sign() produced the same signature for different secrets")
+	}
+
+	c := sign("secret-a", []byte("other-payload"))
+	if a == c {
+		t.Error("sign() produced the same signature for different payloads")
+	}
+}
@@ -0,0 +1,89 @@
+package subscriptions
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RegisterRoutes wires the /api/subscriptions endpoints onto group.
+func RegisterRoutes(group *echo.Group, svc *Service) {
+	group.GET("/subscriptions", svc.listHandler)
+	group.POST("/subscriptions", svc.createHandler)
+	group.DELETE("/subscriptions/:id", svc.deleteHandler)
+	group.GET("/subscriptions/:id/deliveries", svc.deliveriesHandler)
+	group.POST("/subscriptions/:id/test", svc.testHandler)
+}
+
+func (s *Service) listHandler(c echo.Context) error {
+	team := c.QueryParam("team")
+	if team == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "team is required")
+	}
+	subs, err := s.List(c.Request().Context(), team)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, subs)
+}
+
+func (s *Service) createHandler(c echo.Context) error {
+	var sub Subscription
+	if err := c.Bind(&sub); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if sub.Team == "" || sub.TargetURL == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "team and target_url are required")
+	}
+	created, err := s.Create(c.Request().Context(), sub)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	// The secret is only ever returned once, at creation time, so the
+	// operator can store it alongside the target they configured.
+	return c.JSON(http.StatusCreated, struct {
+		Subscription
+		Secret string `json:"secret"`
+	}{created, created.Secret})
+}
+
+func (s *Service) deleteHandler(c echo.Context) error {
+	id := c.Param("id")
+	if err := s.Delete(c.Request().Context(), id); err != nil {
+		if err == ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (s *Service) deliveriesHandler(c echo.Context) error {
+	id := c.Param("id")
+	limit := 50
+	if v := c.QueryParam("limit"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+	deliveries, err := s.RecentDeliveries(c.Request().Context(), id, limit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, deliveries)
+}
+
+func (s *Service) testHandler(c echo.Context) error {
+	sub, err := s.Get(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		if err == ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	if err := s.TestDelivery(c.Request().Context(), sub); err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+	}
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,276 @@
+// Package subscriptions lets operators register webhook targets scoped to
+// a team/app/group/activity_type filter. When an activity entry is created
+// while an Omaha event's consequences are applied (an instance update
+// failure, or a rollout finishing, failing or being rolled back), matching
+// subscriptions receive a signed JSON POST describing it, so operators can
+// wire Nebraska rollout state into Slack, PagerDuty, ArgoCD or their own
+// automation without polling /api/activity. Activity entries created
+// elsewhere, such as for a rollout starting or a channel package update,
+// are not dispatched.
+package subscriptions
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/rs/zerolog/log"
+)
+
+var l = log.With().Str("module", "subscriptions").Logger()
+
+var (
+	// ErrNotFound indicates that the subscription (or delivery) requested
+	// doesn't exist.
+	ErrNotFound = errors.New("subscriptions: not found")
+)
+
+// Subscription is a webhook target scoped to a team/app/group/activity_type
+// filter. An empty AppID/GroupID/ActivityType matches any value, so
+// operators can subscribe broadly or narrowly.
+type Subscription struct {
+	ID           string    `db:"id" json:"id"`
+	Team         string    `db:"team" json:"team"`
+	AppID        string    `db:"app_id" json:"app_id"`
+	GroupID      string    `db:"group_id" json:"group_id"`
+	ActivityType int       `db:"activity_type" json:"activity_type"`
+	TargetURL    string    `db:"target_url" json:"target_url"`
+	Secret       string    `db:"secret" json:"-"`
+	RateLimit    float64   `db:"rate_limit" json:"rate_limit"`
+	CreatedTs    time.Time `db:"created_ts" json:"created_ts"`
+}
+
+// Activity is the payload describing a rollout or instance activity entry
+// that a matching subscription is notified about.
+type Activity struct {
+	Class     int       `json:"class"`
+	Severity  int       `json:"severity"`
+	Version   string    `json:"version"`
+	AppID     string    `json:"app_id"`
+	GroupID   string    `json:"group_id"`
+	Instance  string    `json:"instance_id,omitempty"`
+	CreatedTs time.Time `json:"created_ts"`
+}
+
+// Delivery records a single attempt to deliver an activity to a
+// subscription's target URL.
+type Delivery struct {
+	ID             int64     `db:"id" json:"id"`
+	SubscriptionID string    `db:"subscription_id" json:"subscription_id"`
+	StatusCode     int       `db:"status_code" json:"status_code"`
+	Error          string    `db:"error" json:"error,omitempty"`
+	CreatedTs      time.Time `db:"created_ts" json:"created_ts"`
+}
+
+// Service is the subscriptions subsystem: it stores subscriptions and
+// dispatches matching activity entries to their target URLs.
+type Service struct {
+	db       *sql.DB
+	dispatch *dispatcher
+}
+
+// New creates a Service backed by db.
+func New(db *sql.DB) *Service {
+	return &Service{
+		db:       db,
+		dispatch: newDispatcher(db),
+	}
+}
+
+func newSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Create registers a new subscription. If sub.Secret is empty, a random one
+// is generated so the caller can hand it back to the operator exactly once.
+func (s *Service) Create(ctx context.Context, sub Subscription) (Subscription, error) {
+	if sub.Secret == "" {
+		secret, err := newSecret()
+		if err != nil {
+			return Subscription{}, err
+		}
+		sub.Secret = secret
+	}
+	if sub.RateLimit <= 0 {
+		sub.RateLimit = 5 // deliveries/second, per subscription
+	}
+
+	query, _, err := goqu.Insert("subscription").
+		Cols("team", "app_id", "group_id", "activity_type", "target_url", "secret", "rate_limit").
+		Vals(goqu.Vals{sub.Team, sub.AppID, sub.GroupID, sub.ActivityType, sub.TargetURL, sub.Secret, sub.RateLimit}).
+		Returning("id", "created_ts").
+		ToSQL()
+	if err != nil {
+		return Subscription{}, err
+	}
+	if err := s.db.QueryRowContext(ctx, query).Scan(&sub.ID, &sub.CreatedTs); err != nil {
+		return Subscription{}, err
+	}
+	return sub, nil
+}
+
+// List returns every subscription registered for team.
+func (s *Service) List(ctx context.Context, team string) ([]Subscription, error) {
+	query, _, err := goqu.From("subscription").
+		Select("id", "team", "app_id", "group_id", "activity_type", "target_url", "secret", "rate_limit", "created_ts").
+		Where(goqu.C("team").Eq(team)).
+		Order(goqu.C("created_ts").Desc()).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []Subscription
+	for rows.Next() {
+		var sub Subscription
+		if err := rows.Scan(&sub.ID, &sub.Team, &sub.AppID, &sub.GroupID, &sub.ActivityType, &sub.TargetURL, &sub.Secret, &sub.RateLimit, &sub.CreatedTs); err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// Get returns a single subscription by id.
+func (s *Service) Get(ctx context.Context, id string) (Subscription, error) {
+	query, _, err := goqu.From("subscription").
+		Select("id", "team", "app_id", "group_id", "activity_type", "target_url", "secret", "rate_limit", "created_ts").
+		Where(goqu.C("id").Eq(id)).
+		ToSQL()
+	if err != nil {
+		return Subscription{}, err
+	}
+	var sub Subscription
+	if err := s.db.QueryRowContext(ctx, query).Scan(&sub.ID, &sub.Team, &sub.AppID, &sub.GroupID, &sub.ActivityType, &sub.TargetURL, &sub.Secret, &sub.RateLimit, &sub.CreatedTs); err != nil {
+		if err == sql.ErrNoRows {
+			return Subscription{}, ErrNotFound
+		}
+		return Subscription{}, err
+	}
+	return sub, nil
+}
+
+// Delete removes a subscription by id.
+func (s *Service) Delete(ctx context.Context, id string) error {
+	query, _, err := goqu.Delete("subscription").Where(goqu.C("id").Eq(id)).ToSQL()
+	if err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecentDeliveries returns the most recent deliveries for a subscription,
+// newest first, so operators can inspect recent failures.
+func (s *Service) RecentDeliveries(ctx context.Context, subscriptionID string, limit int) ([]Delivery, error) {
+	query, _, err := goqu.From("subscription_delivery").
+		Select("id", "subscription_id", "status_code", "error", "created_ts").
+		Where(goqu.C("subscription_id").Eq(subscriptionID)).
+		Order(goqu.C("created_ts").Desc()).
+		Limit(uint(limit)).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []Delivery
+	for rows.Next() {
+		var d Delivery
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.StatusCode, &d.Error, &d.CreatedTs); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// Notify queues activity for delivery to every subscription matching its
+// team/app/group/activity_type. It is meant to be called right after a
+// group or instance activity entry is created.
+func (s *Service) Notify(ctx context.Context, team string, activity Activity) {
+	subs, err := s.matching(ctx, team, activity)
+	if err != nil {
+		l.Error().Err(err).Msg("subscriptions: could not load matching subscriptions")
+		return
+	}
+	for _, sub := range subs {
+		s.dispatch.enqueue(sub, activity)
+	}
+}
+
+// TestDelivery synthesizes an activity payload and delivers it to sub
+// synchronously, so operators can verify their endpoint and secret are
+// wired up correctly.
+func (s *Service) TestDelivery(ctx context.Context, sub Subscription) error {
+	activity := Activity{
+		AppID:     sub.AppID,
+		GroupID:   sub.GroupID,
+		CreatedTs: time.Now(),
+	}
+	return deliver(ctx, sub, activity, s.db)
+}
+
+func (s *Service) matching(ctx context.Context, team string, activity Activity) ([]Subscription, error) {
+	query, _, err := goqu.From("subscription").
+		Select("id", "team", "app_id", "group_id", "activity_type", "target_url", "secret", "rate_limit", "created_ts").
+		Where(
+			goqu.C("team").Eq(team),
+			goqu.Or(goqu.C("app_id").Eq(""), goqu.C("app_id").Eq(activity.AppID)),
+			goqu.Or(goqu.C("group_id").Eq(""), goqu.C("group_id").Eq(activity.GroupID)),
+			goqu.Or(goqu.C("activity_type").Eq(0), goqu.C("activity_type").Eq(activity.Class)),
+		).
+		ToSQL()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []Subscription
+	for rows.Next() {
+		var sub Subscription
+		if err := rows.Scan(&sub.ID, &sub.Team, &sub.AppID, &sub.GroupID, &sub.ActivityType, &sub.TargetURL, &sub.Secret, &sub.RateLimit, &sub.CreatedTs); err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// marshalActivity is shared by the dispatcher and TestDelivery so the
+// signed payload is identical for real and synthesized deliveries.
+func marshalActivity(activity Activity) ([]byte, error) {
+	return json.Marshal(activity)
+}